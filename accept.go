@@ -4,7 +4,7 @@ import "strings"
 
 // newHeaderAccept is the single shared implementation for all Accept-* headers.
 func newHeaderAccept(value string, parseType func(string) (string, string, string, error)) (*Header, error) {
-	typ, params, q, err := parseAcceptValue(value)
+	typ, params, q, explicit, err := parseAcceptValue(value)
 	if err != nil {
 		return nil, err
 	}
@@ -15,17 +15,25 @@ func newHeaderAccept(value string, parseType func(string) (string, string, strin
 		return nil, err
 	}
 
-	return newHeader(value, typ, base, sub, q, params), nil
+	h := newHeader(value, typ, base, sub, q, params)
+	h.QualityExplicit = explicit
+
+	return h, nil
 }
 
 // newMedia creates a new Header for a media type from a header value.
+// Parameters without a type (";q=0.5") are an invalid media type.
 func newMedia(value string) (*Header, error) {
+	if typ, _, _ := strings.Cut(value, ";"); strings.TrimSpace(typ) == "" {
+		return nil, &InvalidMediaTypeError{}
+	}
+
 	return newHeaderAccept(value, func(typ string) (string, string, string, error) {
 		if typ == "*" {
 			typ = "*/*"
 		}
 		parts := strings.SplitN(typ, "/", 2)
-		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" || strings.Contains(parts[1], "/") {
 			return "", "", "", &InvalidMediaTypeError{}
 		}
 
@@ -34,23 +42,124 @@ func newMedia(value string) (*Header, error) {
 }
 
 // newLanguage creates a new Header for a language from a header value.
+// Any well-formed BCP 47 tag is accepted, including grandfathered and irregular
+// tags such as "i-klingon" or "zh-min-nan"; BasePart is the primary language subtag.
+// A 4-letter subtag in the second position is exposed as ScriptPart.
 func newLanguage(value string) (*Header, error) {
-	return newHeaderAccept(value, func(typ string) (string, string, string, error) {
+	var script string
+
+	h, err := newHeaderAccept(value, func(typ string) (string, string, string, error) {
 		parts := strings.Split(typ, "-")
+		if !validLanguageSubtags(parts) {
+			return "", "", "", &InvalidLanguageError{}
+		}
+
+		if len(parts) > 1 && len(parts[1]) == 4 && isAlpha(parts[1]) {
+			script = parts[1]
+		}
+
 		switch len(parts) {
 		case 1:
 			return typ, parts[0], "", nil
 		case 2:
+			if script != "" { // zh-Hans
+				return typ, parts[0], "", nil
+			}
+
 			return typ, parts[0], parts[1], nil
-		case 3: // zh-Hans-CN
-			return typ, parts[0], parts[2], nil
-		default:
-			return "", "", "", &InvalidLanguageError{}
+		default: // zh-Hans-CN, de-CH-1901, en-US-x-twain
+			return typ, parts[0], findRegionSubtag(parts[1:]), nil
 		}
 	})
+	if err != nil {
+		return nil, err
+	}
+	h.ScriptPart = script
+	h.NormalizedValue = buildNormalizedValue(canonicalLanguageTag(h.Type), h.Parameters)
+
+	return h, nil
+}
+
+// canonicalLanguageTag returns a lowercase language tag with the BCP 47 subtag
+// casing conventions (RFC 5646, section 2.1.1): 2-letter region subtags are
+// uppercased and 4-letter script subtags titlecased, e.g. "zh-Hans-CN".
+// Subtags following a singleton such as "x" are left lowercase.
+func canonicalLanguageTag(tag string) string {
+	parts := strings.Split(tag, "-")
+	for i := 1; i < len(parts); i++ {
+		part := parts[i]
+		if len(part) == 1 {
+			break
+		}
+
+		switch {
+		case len(part) == 2 && isAlpha(part):
+			parts[i] = strings.ToUpper(part)
+		case len(part) == 4 && isAlpha(part):
+			parts[i] = strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+
+	return strings.Join(parts, "-")
+}
+
+// validLanguageSubtags checks that every subtag is 1-8 alphanumeric characters.
+// The primary subtag may also be the "*" wildcard.
+func validLanguageSubtags(parts []string) bool {
+	if len(parts) == 1 && parts[0] == "*" {
+		return true
+	}
+
+	for _, part := range parts {
+		if len(part) == 0 || len(part) > 8 {
+			return false
+		}
+		for i := 0; i < len(part); i++ {
+			c := part[i]
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// findRegionSubtag returns the first region-like subtag (2 letters or 3 digits).
+func findRegionSubtag(subtags []string) string {
+	for _, subtag := range subtags {
+		if (len(subtag) == 2 && isAlpha(subtag)) || (len(subtag) == 3 && isDigit(subtag)) {
+			return subtag
+		}
+	}
+
+	return ""
+}
+
+// isAlpha reports whether s consists of lowercase ASCII letters only.
+func isAlpha(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 'a' || s[i] > 'z' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isDigit reports whether s consists of ASCII digits only.
+func isDigit(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return true
 }
 
 // newCharset creates a new Header for a charset from a header value.
+// Parameters are kept as for media types, although they never affect matching.
 func newCharset(value string) (*Header, error) {
 	return newHeaderAccept(value, func(typ string) (string, string, string, error) {
 		return typ, "", "", nil
@@ -58,8 +167,44 @@ func newCharset(value string) (*Header, error) {
 }
 
 // newEncoding creates a new Header for an encoding from a header value.
+// Parameters, such as the non-standard compression level hints of some clients
+// ("br;level=5"), are kept as for media types, although they never affect matching.
 func newEncoding(value string) (*Header, error) {
 	return newHeaderAccept(value, func(typ string) (string, string, string, error) {
 		return typ, "", "", nil
 	})
 }
+
+// newGeneric returns a factory for a custom Accept-like header whose lowercase types
+// are validated and normalized by parseType. The "*" wildcard is never passed to parseType.
+func newGeneric(parseType func(string) (string, error)) headerFactory {
+	return func(value string) (*Header, error) {
+		return newHeaderAccept(value, func(typ string) (string, string, string, error) {
+			if parseType == nil || typ == "*" {
+				return typ, "", "", nil
+			}
+
+			typ, err := parseType(typ)
+			if err != nil {
+				return "", "", "", err
+			}
+
+			return typ, "", "", nil
+		})
+	}
+}
+
+// newTransferCoding creates a new Header for a TE transfer coding from a header value.
+// The "trailers" keyword only signals presence, so any q on it is ignored.
+func newTransferCoding(value string) (*Header, error) {
+	h, err := newEncoding(value)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.Type == "trailers" {
+		h.Quality = 1.0
+	}
+
+	return h, nil
+}
@@ -1,6 +1,7 @@
 package negotiation
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -45,6 +46,27 @@ func TestNewMedia_NormalizedValue(t *testing.T) {
 	}
 }
 
+func TestNewMedia_QualityExplicit(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		expected bool
+	}{
+		{"omitted", "text/html", false},
+		{"explicit q=1", "text/html;q=1", true},
+		{"explicit lower", "text/html;q=0.5", true},
+		{"uppercase Q", "text/html; Q=1.0", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acc, err := newMedia(tt.header)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, acc.QualityExplicit)
+		})
+	}
+}
+
 func TestNewMedia_Type(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -83,6 +105,8 @@ func TestNewMedia_Invalid(t *testing.T) {
 		{"no slash", "text"},
 		{"empty type", "/html"},
 		{"empty subtype", "text/"},
+		{"double slash", "application//json"},
+		{"extra slash", "invalid/header/format"},
 	}
 
 	for _, tt := range tests {
@@ -130,6 +154,31 @@ func TestHeader_ParametersMap(t *testing.T) {
 	assert.Equal(t, "UTF-8", acc.Parameters["charset"])
 }
 
+func TestNewMedia_Multipart(t *testing.T) {
+	tests := []struct {
+		value    string
+		typ      string
+		sub      string
+		boundary string
+	}{
+		{"multipart/form-data; boundary=AaB03x", "multipart/form-data", "form-data", "AaB03x"},
+		{"Multipart/Mixed; Boundary=gc0p4Jq0M2Yt08jU534c0p", "multipart/mixed", "mixed", "gc0p4Jq0M2Yt08jU534c0p"},
+		{`multipart/mixed; boundary="simple boundary"`, "multipart/mixed", "mixed", "simple boundary"},
+		{`multipart/alternative; boundary="--=_Part;1,Xy"`, "multipart/alternative", "alternative", "--=_Part;1,Xy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			acc, err := newMedia(tt.value)
+			require.NoError(t, err)
+			assert.Equal(t, tt.typ, acc.Type)
+			assert.Equal(t, "multipart", acc.BasePart)
+			assert.Equal(t, tt.sub, acc.SubPart)
+			assert.Equal(t, map[string]string{"boundary": tt.boundary}, acc.Parameters)
+		})
+	}
+}
+
 func TestNewLanguage_Type(t *testing.T) {
 	tests := []struct {
 		name         string
@@ -144,6 +193,17 @@ func TestNewLanguage_Type(t *testing.T) {
 		{"case insensitive", "EN-us", "en-us", "en", "us"},
 		{"with parameters", "en;q=0.8", "en", "en", ""},
 		{"with region and parameters", "fr-CA;q=0.9", "fr-ca", "fr", "ca"},
+		{"grandfathered", "i-klingon", "i-klingon", "i", "klingon"},
+		{"extended language subtags", "zh-min-nan", "zh-min-nan", "zh", ""},
+		{"variants only", "sl-rozaj-biske", "sl-rozaj-biske", "sl", ""},
+		{"region and variant", "de-CH-1901", "de-ch-1901", "de", "ch"},
+		{"region and posix variant", "en-US-posix", "en-us-posix", "en", "us"},
+		{"four subtags", "zh-Hans-CN-TW", "zh-hans-cn-tw", "zh", "cn"},
+		{"private use", "en-US-x-twain", "en-us-x-twain", "en", "us"},
+		{"numeric region", "es-Latn-419-valencia", "es-latn-419-valencia", "es", "419"},
+		{"no region", "sl-rozaj-biske-1994", "sl-rozaj-biske-1994", "sl", ""},
+		{"wildcard", "*", "*", "*", ""},
+		{"wildcard with quality", "*;q=0.1", "*", "*", ""},
 	}
 
 	for _, tt := range tests {
@@ -157,13 +217,76 @@ func TestNewLanguage_Type(t *testing.T) {
 	}
 }
 
+func TestNewLanguage_ScriptPart(t *testing.T) {
+	tests := []struct {
+		name           string
+		header         string
+		expectedBase   string
+		expectedScript string
+		expectedSub    string
+	}{
+		{"script and region", "zh-Hans-CN", "zh", "hans", "cn"},
+		{"script only", "zh-Hant", "zh", "hant", ""},
+		{"region only", "en-US", "en", "", "us"},
+		{"script with variant", "sr-Latn-RS-ekavsk", "sr", "latn", "rs"},
+		{"no script", "en", "en", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acc, err := newLanguage(tt.header)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedBase, acc.BasePart)
+			assert.Equal(t, tt.expectedScript, acc.ScriptPart)
+			assert.Equal(t, tt.expectedSub, acc.SubPart)
+		})
+	}
+
+	// Other header types never carry a script.
+	media, err := newMedia("text/html")
+	require.NoError(t, err)
+	assert.Empty(t, media.ScriptPart)
+}
+
+func TestNewLanguage_NormalizedValue(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected string
+	}{
+		{"zh-hans-cn", "zh-Hans-CN"},
+		{"ZH-HANT", "zh-Hant"},
+		{"EN-us", "en-US"},
+		{"es-419", "es-419"},
+		{"sr-latn-rs-ekavsk", "sr-Latn-RS-ekavsk"},
+		{"de-de-1996", "de-DE-1996"},
+		{"en-us-x-twain", "en-US-x-twain"},
+		{"en-x-ab-abcd", "en-x-ab-abcd"},
+		{"i-klingon", "i-klingon"},
+		{"zh-min-nan", "zh-min-nan"},
+		{"*", "*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			acc, err := newLanguage(tt.header)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, acc.NormalizedValue)
+			assert.Equal(t, tt.expected, acc.String())
+			assert.Equal(t, strings.ToLower(tt.expected), acc.Type, "comparison uses the lowercase type")
+		})
+	}
+}
+
 func TestNewLanguage_Invalid(t *testing.T) {
 	tests := []struct {
 		name   string
 		header string
 	}{
-		{"too many parts", "en-US-CA-GB"},
-		{"four parts", "zh-Hans-CN-TW"},
+		{"empty subtag", "en--US"},
+		{"trailing hyphen", "en-"},
+		{"leading hyphen", "-en"},
+		{"illegal characters", "en_US"},
+		{"subtag too long", "en-abcdefghi"},
 	}
 
 	for _, tt := range tests {
@@ -222,6 +345,41 @@ func TestNewEncoding_Type(t *testing.T) {
 	}
 }
 
+func TestNewEncoding_Parameters(t *testing.T) {
+	tests := []struct {
+		name            string
+		header          string
+		expectedType    string
+		expectedQuality float64
+		expectedParams  map[string]string
+	}{
+		{"parameter after quality", "gzip;q=0.9;foo=bar", "gzip", 0.9, map[string]string{"foo": "bar"}},
+		{"level hint", "br;q=1.0;level=5", "br", 1.0, map[string]string{"level": "5"}},
+		{"parameter before quality", "zstd; Level=19; q=0.5", "zstd", 0.5, map[string]string{"level": "19"}},
+		{"quoted value", `gzip;note="a, b"`, "gzip", 1.0, map[string]string{"note": "a, b"}},
+		{"no parameters", "deflate;q=0.1", "deflate", 0.1, map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acc, err := newEncoding(tt.header)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedType, acc.Type)
+			assert.InDelta(t, tt.expectedQuality, acc.Quality, 1e-9)
+			assert.Equal(t, tt.expectedParams, acc.Parameters)
+		})
+	}
+}
+
+func TestNewCharset_Parameters(t *testing.T) {
+	acc, err := newCharset("UTF-8;q=0.7;foo=bar")
+	require.NoError(t, err)
+	assert.Equal(t, "utf-8", acc.Type)
+	assert.InDelta(t, 0.7, acc.Quality, 1e-9)
+	assert.Equal(t, map[string]string{"foo": "bar"}, acc.Parameters)
+	assert.Equal(t, "utf-8; foo=bar", acc.NormalizedValue)
+}
+
 func TestNewCharset_Value(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -259,3 +417,26 @@ func TestNewEncoding_Value(t *testing.T) {
 		})
 	}
 }
+
+func TestNewTransferCoding(t *testing.T) {
+	tests := []struct {
+		name            string
+		header          string
+		expectedType    string
+		expectedQuality float64
+	}{
+		{"coding", "gzip", "gzip", 1.0},
+		{"coding with quality", "deflate;q=0.5", "deflate", 0.5},
+		{"trailers", "trailers", "trailers", 1.0},
+		{"trailers ignores quality", "Trailers;q=0.1", "trailers", 1.0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acc, err := newTransferCoding(tt.header)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedType, acc.Type)
+			assert.Equal(t, tt.expectedQuality, acc.Quality)
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package negotiation
+
+import (
+	"encoding/binary"
+	"hash/maphash"
+	"math"
+	"slices"
+	"sync"
+)
+
+// resultCache memoizes negotiation results by header and priority list.
+// It is safe for concurrent use.
+type resultCache struct {
+	mu      sync.Mutex
+	size    int
+	seed    maphash.Seed
+	entries map[resultKey]*cachedResult
+}
+
+// resultKey identifies a negotiation; priorities is a hash of the priority list.
+type resultKey struct {
+	header        string
+	priorities    uint64
+	strict        bool
+	serverQuality bool
+}
+
+// cachedResult is the memoized outcome of a negotiation.
+type cachedResult struct {
+	// priorities is the priority list negotiated, compared on lookup to rule out hash collisions.
+	priorities []WeightedPriority
+	// best is the winning priority; nil if err is set.
+	best *Header
+	// quality is the resolved quality of best.
+	quality float64
+	err     error
+}
+
+// newResultCache creates a cache holding at most size results.
+func newResultCache(size int) *resultCache {
+	return &resultCache{
+		size:    size,
+		seed:    maphash.MakeSeed(),
+		entries: make(map[resultKey]*cachedResult, size),
+	}
+}
+
+// key returns the cache key of a negotiation.
+func (rc *resultCache) key(header string, priorities []WeightedPriority, strict, serverQuality bool) resultKey {
+	var h maphash.Hash
+	h.SetSeed(rc.seed)
+
+	var buf [8]byte
+	for _, p := range priorities {
+		h.WriteString(p.Value)
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(p.Weight))
+		h.Write(buf[:])
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(p.Cost))
+		h.Write(buf[:])
+	}
+
+	return resultKey{header: header, priorities: h.Sum64(), strict: strict, serverQuality: serverQuality}
+}
+
+// get returns the cached result of a negotiation, if any. A nil cache never hits.
+func (rc *resultCache) get(key resultKey, priorities []WeightedPriority) (*cachedResult, bool) {
+	if rc == nil {
+		return nil, false
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	r, ok := rc.entries[key]
+	if !ok || !slices.Equal(r.priorities, priorities) {
+		return nil, false
+	}
+
+	return r, true
+}
+
+// put stores the result of a negotiation, evicting an arbitrary entry when full.
+// A nil cache stores nothing.
+func (rc *resultCache) put(key resultKey, r *cachedResult) {
+	if rc == nil {
+		return
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if _, ok := rc.entries[key]; !ok && len(rc.entries) >= rc.size {
+		for k := range rc.entries {
+			delete(rc.entries, k)
+
+			break
+		}
+	}
+	rc.entries[key] = r
+}
+
+// clear removes all cached results. A nil cache is left alone.
+func (rc *resultCache) clear() {
+	if rc == nil {
+		return
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	clear(rc.entries)
+}
@@ -0,0 +1,191 @@
+package negotiation
+
+import (
+	"cmp"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithResultCache(t *testing.T) {
+	negotiator := NewMediaNegotiator(WithResultCache(16))
+	header := "text/html;q=0.5, application/json"
+	priorities := []string{"text/html", "application/json; charset=utf-8"}
+
+	first, err := negotiator.Negotiate(header, priorities, false)
+	require.NoError(t, err)
+	require.Len(t, negotiator.cache.entries, 1)
+
+	second, err := negotiator.Negotiate(header, priorities, false)
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.Len(t, negotiator.cache.entries, 1, "served from the cache")
+
+	// Results are copies.
+	second.Parameters["charset"] = "latin1"
+	third, err := negotiator.Negotiate(header, priorities, false)
+	require.NoError(t, err)
+	assert.Equal(t, "utf-8", third.Parameters["charset"])
+	assert.NotSame(t, second, third)
+}
+
+func TestWithResultCache_PrioritiesChange(t *testing.T) {
+	negotiator := NewMediaNegotiator(WithResultCache(16))
+	header := "text/html, application/json"
+	priorities := []string{"text/html", "application/json"}
+
+	result, err := negotiator.Negotiate(header, priorities, false)
+	require.NoError(t, err)
+	assert.Equal(t, "text/html", result.Value)
+
+	// Reordering the same slice must not hit the old result.
+	priorities[0], priorities[1] = priorities[1], priorities[0]
+	result, err = negotiator.Negotiate(header, priorities, false)
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", result.Value)
+
+	// Weights are part of the key.
+	result, err = negotiator.NegotiateWeighted(header, []WeightedPriority{{Value: "text/html", Weight: 1}, {Value: "application/json", Weight: 2}}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", result.Value)
+
+	result, err = negotiator.NegotiateWeighted(header, []WeightedPriority{{Value: "text/html", Weight: 2}, {Value: "application/json", Weight: 1}}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "text/html", result.Value)
+}
+
+func TestWithResultCache_ModeIsPartOfKey(t *testing.T) {
+	negotiator := NewMediaNegotiator(WithResultCache(16))
+	header := "text/html;q=bogus, application/json;q=0.5"
+	priorities := []string{"text/html", "application/json"}
+
+	result, err := negotiator.Negotiate(header, priorities, false)
+	require.NoError(t, err)
+	assert.Equal(t, "text/html", result.Value)
+
+	_, err = negotiator.Negotiate(header, priorities, true)
+	var qualityErr *InvalidQualityError
+	require.ErrorAs(t, err, &qualityErr)
+
+	result, err = negotiator.NegotiateServerQuality("text/html, application/json", []string{"text/html;q=0.5", "application/json"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", result.Value)
+
+	result, err = negotiator.Negotiate("text/html, application/json", []string{"text/html;q=0.5", "application/json"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "text/html;q=0.5", result.Value)
+}
+
+func TestWithResultCache_Errors(t *testing.T) {
+	observer := &recordingObserver{}
+	negotiator := NewMediaNegotiator(WithResultCache(16), WithObserver(observer))
+
+	for range 2 {
+		_, err := negotiator.Negotiate("image/png", []string{"text/html"}, false)
+		require.ErrorIs(t, err, ErrNoAcceptableMatch)
+
+		_, err = negotiator.Negotiate("", []string{"text/html"}, false)
+		require.ErrorIs(t, err, ErrEmptyHeader)
+
+		result, err := negotiator.Negotiate("text/html;q=0.8", []string{"text/html"}, false)
+		require.NoError(t, err)
+		assert.Equal(t, "text/html", result.Value)
+	}
+
+	assert.Len(t, negotiator.cache.entries, 3)
+
+	// The observer sees cache hits as it sees negotiations.
+	assert.Equal(t, []string{"Accept", "Accept"}, observer.noMatches)
+	assert.Equal(t, []string{"Accept text/html", "Accept text/html"}, observer.matches)
+	assert.Equal(t, []float64{0.8, 0.8}, observer.qualities)
+}
+
+func TestWithResultCache_Bounded(t *testing.T) {
+	negotiator := NewMediaNegotiator(WithResultCache(4))
+
+	for i := range 20 {
+		_, err := negotiator.Negotiate("text/html;level="+strconv.Itoa(i), []string{"text/html"}, false)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(negotiator.cache.entries), 4)
+	}
+}
+
+func TestWithResultCache_Disabled(t *testing.T) {
+	assert.Nil(t, NewMediaNegotiator().cache)
+	assert.Nil(t, NewMediaNegotiator(WithResultCache(0)).cache)
+	assert.Nil(t, NewMediaNegotiator(WithResultCache(-1)).cache)
+}
+
+func TestWithResultCache_SetComparatorClears(t *testing.T) {
+	negotiator := NewMediaNegotiator(WithResultCache(16))
+	header := "text/html, application/json"
+	priorities := []string{"text/html", "application/json"}
+
+	result, err := negotiator.Negotiate(header, priorities, false)
+	require.NoError(t, err)
+	assert.Equal(t, "text/html", result.Value)
+
+	negotiator.SetComparator(func(a, b *Header) int {
+		return cmp.Compare(a.Type, b.Type)
+	})
+	assert.Empty(t, negotiator.cache.entries)
+
+	result, err = negotiator.Negotiate(header, priorities, false)
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", result.Value)
+}
+
+func TestWithResultCache_Concurrent(t *testing.T) {
+	negotiator := NewMediaNegotiator(WithResultCache(8))
+	headers := []string{"text/html", "application/json", "text/*;q=0.5, application/json", "image/png"}
+	priorities := []string{"application/json", "text/html"}
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 100 {
+				header := headers[(i+j)%len(headers)]
+				result, err := negotiator.Negotiate(header, priorities, false)
+				if header == "image/png" {
+					assert.ErrorIs(t, err, ErrNoAcceptableMatch)
+
+					continue
+				}
+				if assert.NoError(t, err) {
+					result.Parameters["x"] = "y"
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkNegotiate(b *testing.B) {
+	header := "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8"
+	priorities := []string{"application/json", "application/xml", "text/html", "text/plain"}
+
+	b.Run("uncached", func(b *testing.B) {
+		negotiator := NewMediaNegotiator()
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := negotiator.Negotiate(header, priorities, false); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		negotiator := NewMediaNegotiator(WithResultCache(64))
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := negotiator.Negotiate(header, priorities, false); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,177 @@
+package negotiation
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+)
+
+// charsetAliases maps common charset aliases to their preferred IANA MIME name.
+// Names are lowercase, as types are lowercased by the parser.
+var charsetAliases = map[string]string{
+	"utf8":                "utf-8",
+	"csutf8":              "utf-8",
+	"unicode-1-1-utf-8":   "utf-8",
+	"unicode11utf8":       "utf-8",
+	"unicode20utf8":       "utf-8",
+	"x-unicode20utf8":     "utf-8",
+	"utf16":               "utf-16",
+	"csutf16":             "utf-16",
+	"utf16be":             "utf-16be",
+	"csutf16be":           "utf-16be",
+	"utf16le":             "utf-16le",
+	"csutf16le":           "utf-16le",
+	"ascii":               "us-ascii",
+	"us":                  "us-ascii",
+	"csascii":             "us-ascii",
+	"iso646-us":           "us-ascii",
+	"iso-ir-6":            "us-ascii",
+	"ansi_x3.4-1968":      "us-ascii",
+	"ansi_x3.4-1986":      "us-ascii",
+	"iso_646.irv:1991":    "us-ascii",
+	"ibm367":              "us-ascii",
+	"cp367":               "us-ascii",
+	"latin1":              "iso-8859-1",
+	"l1":                  "iso-8859-1",
+	"iso8859-1":           "iso-8859-1",
+	"iso88591":            "iso-8859-1",
+	"iso_8859-1":          "iso-8859-1",
+	"iso_8859-1:1987":     "iso-8859-1",
+	"iso-ir-100":          "iso-8859-1",
+	"ibm819":              "iso-8859-1",
+	"cp819":               "iso-8859-1",
+	"csisolatin1":         "iso-8859-1",
+	"latin2":              "iso-8859-2",
+	"l2":                  "iso-8859-2",
+	"iso8859-2":           "iso-8859-2",
+	"iso_8859-2":          "iso-8859-2",
+	"iso_8859-2:1987":     "iso-8859-2",
+	"iso-ir-101":          "iso-8859-2",
+	"csisolatin2":         "iso-8859-2",
+	"latin-9":             "iso-8859-15",
+	"latin9":              "iso-8859-15",
+	"l9":                  "iso-8859-15",
+	"iso8859-15":          "iso-8859-15",
+	"iso_8859-15":         "iso-8859-15",
+	"csiso885915":         "iso-8859-15",
+	"cp1251":              "windows-1251",
+	"x-cp1251":            "windows-1251",
+	"cswindows1251":       "windows-1251",
+	"cp1252":              "windows-1252",
+	"x-cp1252":            "windows-1252",
+	"cswindows1252":       "windows-1252",
+	"koi8":                "koi8-r",
+	"cskoi8r":             "koi8-r",
+	"sjis":                "shift_jis",
+	"shift-jis":           "shift_jis",
+	"x-sjis":              "shift_jis",
+	"ms_kanji":            "shift_jis",
+	"csshiftjis":          "shift_jis",
+	"eucjp":               "euc-jp",
+	"x-euc-jp":            "euc-jp",
+	"cseucpkdfmtjapanese": "euc-jp",
+	"csiso2022jp":         "iso-2022-jp",
+	"euckr":               "euc-kr",
+	"cseuckr":             "euc-kr",
+	"cp936":               "gbk",
+	"ms936":               "gbk",
+	"windows-936":         "gbk",
+	"x-gbk":               "gbk",
+	"csgbk":               "gbk",
+	"csgb18030":           "gb18030",
+	"csbig5":              "big5",
+}
+
+// newCharsetAliases returns the built-in alias table extended with extra aliases.
+// Extra aliases take precedence and are lowercased to match parsed types.
+func newCharsetAliases(extra map[string]string) map[string]string {
+	aliases := maps.Clone(charsetAliases)
+	for alias, canonical := range extra {
+		aliases[strings.ToLower(alias)] = strings.ToLower(canonical)
+	}
+
+	return aliases
+}
+
+// resolveAlias returns the canonical lowercase name of value if it is a known alias,
+// or value lowercased otherwise.
+func resolveAlias(aliases map[string]string, value string) string {
+	value = strings.ToLower(value)
+	if canonical, ok := aliases[value]; ok {
+		return canonical
+	}
+
+	return value
+}
+
+// CharsetOf returns the charset parameter of a media type header,
+// e.g. "utf-8" for "text/html; charset=utf-8", or "" if it has none.
+func CharsetOf(h *Header) string {
+	if h == nil {
+		return ""
+	}
+
+	return h.Parameters["charset"]
+}
+
+// NegotiateMediaAndCharset negotiates the media type and then the charset of a
+// response from the Accept and Accept-Charset header values. A charset named by
+// the chosen media priority, or otherwise by the client range it matched (as in
+// "text/html;charset=utf-8"), constrains the charset: only charset priorities
+// naming it, across aliases, are negotiated, and if none is acceptable the result
+// is ErrNoAcceptableMatch. Without such a charset, the charset is negotiated on
+// its own. Without charset priorities, the charset is not negotiated and the
+// returned charset is nil. As for NegotiateAll, an absent header accepts
+// anything, headers are parsed leniently, and errors are prefixed with the
+// header name.
+func NegotiateMediaAndCharset(acceptMedia, acceptCharset string, mediaPriorities, charsetPriorities []string) (*Header, *Header, error) {
+	if strings.TrimSpace(acceptMedia) == "" {
+		acceptMedia = "*/*"
+	}
+
+	n, err := NewMediaNegotiator(WithCharsetParamMatching(true)).negotiate(acceptMedia, unweighted(mediaPriorities), false, false)
+	if err == nil && n.best == nil {
+		err = ErrNoAcceptableMatch
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("Accept: %w", err)
+	}
+	media := n.bestHeader()
+	if len(charsetPriorities) == 0 {
+		return media, nil, nil
+	}
+
+	required := CharsetOf(media)
+	if required == "" {
+		required = CharsetOf(n.best.Accept)
+	}
+	if required != "" {
+		charsetPriorities = charsetsNamed(charsetPriorities, required)
+		if len(charsetPriorities) == 0 {
+			return nil, nil, fmt.Errorf("Accept-Charset: %w", ErrNoAcceptableMatch)
+		}
+	}
+
+	charset, err := negotiateRequestHeader(NewCharsetNegotiator(), acceptCharset, charsetPriorities, false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Accept-Charset: %w", err)
+	}
+
+	return media, charset, nil
+}
+
+// charsetsNamed returns the charset priorities naming charset, compared across
+// the built-in aliases.
+func charsetsNamed(priorities []string, charset string) []string {
+	want := resolveAlias(charsetAliases, strings.TrimSpace(charset))
+
+	named := make([]string, 0, len(priorities))
+	for _, p := range priorities {
+		typ, _, _ := strings.Cut(p, ";")
+		if resolveAlias(charsetAliases, strings.TrimSpace(typ)) == want {
+			named = append(named, p)
+		}
+	}
+
+	return named
+}
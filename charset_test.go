@@ -0,0 +1,161 @@
+package negotiation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCharsetOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		expected string
+	}{
+		{"with charset", "text/html; charset=utf-8", "utf-8"},
+		{"quoted charset", `text/plain; format=flowed; Charset="ISO-8859-1"`, "ISO-8859-1"},
+		{"without charset", "application/json", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := newMedia(tt.header)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, CharsetOf(h))
+		})
+	}
+
+	assert.Empty(t, CharsetOf(nil))
+}
+
+func TestNewCharsetAliases(t *testing.T) {
+	aliases := newCharsetAliases(map[string]string{"X-Mac-Roman": "Macintosh", "utf8": "x-custom"})
+
+	assert.Equal(t, "macintosh", aliases["x-mac-roman"])
+	assert.Equal(t, "x-custom", aliases["utf8"], "extra aliases take precedence")
+	assert.Equal(t, "utf-8", charsetAliases["utf8"], "the built-in table is not modified")
+}
+
+func TestNegotiateMediaAndCharset(t *testing.T) {
+	mediaPriorities := []string{"text/html", "application/json"}
+	charsetPriorities := []string{"iso-8859-1", "utf-8"}
+
+	tests := []struct {
+		name              string
+		acceptMedia       string
+		acceptCharset     string
+		mediaPriorities   []string
+		charsetPriorities []string
+		expectedMedia     string
+		expectedCharset   string
+		expectErr         error
+	}{
+		{
+			name:              "charset from media range without Accept-Charset",
+			acceptMedia:       "text/html;charset=utf-8",
+			mediaPriorities:   mediaPriorities,
+			charsetPriorities: charsetPriorities,
+			expectedMedia:     "text/html",
+			expectedCharset:   "utf-8",
+		},
+		{
+			name:              "media range charset constrains Accept-Charset",
+			acceptMedia:       "text/html;charset=utf-8",
+			acceptCharset:     "iso-8859-1, utf-8;q=0.5",
+			mediaPriorities:   mediaPriorities,
+			charsetPriorities: charsetPriorities,
+			expectedMedia:     "text/html",
+			expectedCharset:   "utf-8",
+		},
+		{
+			name:              "media range charset across aliases",
+			acceptMedia:       "text/html;charset=UTF8",
+			mediaPriorities:   mediaPriorities,
+			charsetPriorities: charsetPriorities,
+			expectedMedia:     "text/html",
+			expectedCharset:   "utf-8",
+		},
+		{
+			name:              "charset fixed by media priority",
+			acceptMedia:       "text/*",
+			acceptCharset:     "iso-8859-1, utf-8;q=0.5",
+			mediaPriorities:   []string{"text/html; charset=utf-8"},
+			charsetPriorities: charsetPriorities,
+			expectedMedia:     "text/html; charset=utf-8",
+			expectedCharset:   "utf-8",
+		},
+		{
+			name:              "Accept-Charset alone",
+			acceptMedia:       "text/html",
+			acceptCharset:     "utf-8, iso-8859-1;q=0.5",
+			mediaPriorities:   mediaPriorities,
+			charsetPriorities: charsetPriorities,
+			expectedMedia:     "text/html",
+			expectedCharset:   "utf-8",
+		},
+		{
+			name:              "absent headers accept anything",
+			mediaPriorities:   mediaPriorities,
+			charsetPriorities: charsetPriorities,
+			expectedMedia:     "text/html",
+			expectedCharset:   "iso-8859-1",
+		},
+		{
+			name:              "constraint rejected by Accept-Charset",
+			acceptMedia:       "text/html;charset=utf-8",
+			acceptCharset:     "iso-8859-1",
+			mediaPriorities:   mediaPriorities,
+			charsetPriorities: charsetPriorities,
+			expectErr:         ErrNoAcceptableMatch,
+		},
+		{
+			name:              "constraint not available",
+			acceptMedia:       "text/html;charset=shift_jis",
+			mediaPriorities:   mediaPriorities,
+			charsetPriorities: charsetPriorities,
+			expectErr:         ErrNoAcceptableMatch,
+		},
+		{
+			name:              "media not acceptable",
+			acceptMedia:       "image/png",
+			mediaPriorities:   mediaPriorities,
+			charsetPriorities: charsetPriorities,
+			expectErr:         ErrNoAcceptableMatch,
+		},
+		{
+			name:            "no charset priorities",
+			acceptMedia:     "text/html;charset=utf-8",
+			acceptCharset:   "koi8-r",
+			mediaPriorities: mediaPriorities,
+			expectedMedia:   "text/html",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			media, charset, err := NegotiateMediaAndCharset(tt.acceptMedia, tt.acceptCharset, tt.mediaPriorities, tt.charsetPriorities)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+				assert.Nil(t, media)
+				assert.Nil(t, charset)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedMedia, media.Value)
+			if tt.expectedCharset == "" {
+				assert.Nil(t, charset, "the charset is not negotiated")
+
+				return
+			}
+			assert.Equal(t, tt.expectedCharset, charset.Value)
+		})
+	}
+
+	_, _, err := NegotiateMediaAndCharset("image/png", "", mediaPriorities, charsetPriorities)
+	require.ErrorContains(t, err, "Accept: ")
+	_, _, err = NegotiateMediaAndCharset("text/html", "koi8-r", mediaPriorities, charsetPriorities)
+	require.ErrorContains(t, err, "Accept-Charset: ")
+}
@@ -0,0 +1,98 @@
+package negotiation
+
+import (
+	"math"
+	"slices"
+	"strings"
+)
+
+// BuildAcceptHeader builds a compact Accept header for an outbound request from
+// client preferences, the weight of each preference being its q-value. Elements
+// are in canonical form as produced by Normalize: sorted by weight descending
+// (keeping the given order for ties), types lowercased, parameters sorted, weights
+// clamped to [0, 1] and rounded to three decimals, and q=1 omitted. Preferences
+// that are not valid media types are skipped and duplicates are collapsed, so
+// NewMediaNegotiator().Normalize returns the built header unchanged.
+func BuildAcceptHeader(prefs []WeightedPriority) string {
+	elements := make([]*Header, 0, len(prefs))
+	for i, p := range prefs {
+		h, err := newMedia(stripQuality(p.Value))
+		if err != nil {
+			continue
+		}
+		h.Quality = math.Round(min(max(p.Weight, 0), 1)*1000) / 1000
+		h.originalIndex = i
+		elements = append(elements, h)
+	}
+
+	elements = dedupElements(elements)
+	slices.SortStableFunc(elements, DefaultComparator)
+
+	parts := make([]string, 0, len(elements))
+	for _, e := range elements {
+		parts = append(parts, formatElement(e))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// AcceptBuilder builds an Accept header for an outbound request step by step,
+// in the canonical form of BuildAcceptHeader. The zero value is ready to use.
+type AcceptBuilder struct {
+	prefs    []WeightedPriority
+	excluded []string
+}
+
+// NewAcceptBuilder returns an empty AcceptBuilder.
+func NewAcceptBuilder() *AcceptBuilder {
+	return &AcceptBuilder{}
+}
+
+// Add accepts a media type or range with the given weight as its q-value.
+func (b *AcceptBuilder) Add(value string, weight float64) *AcceptBuilder {
+	b.prefs = append(b.prefs, WeightedPriority{Value: value, Weight: weight})
+
+	return b
+}
+
+// Exclude refuses a media type by emitting it with q=0, along with a "*/*"
+// catch-all unless one was added, so the header accepts anything but the
+// excluded type: Exclude("application/xml") builds "*/*, application/xml; q=0".
+// An excluded type is refused even if it was also added.
+func (b *AcceptBuilder) Exclude(typ string) *AcceptBuilder {
+	b.excluded = append(b.excluded, typ)
+
+	return b
+}
+
+// Build returns the header, as BuildAcceptHeader would for the added preferences.
+func (b *AcceptBuilder) Build() string {
+	excluded := make(map[string]bool, len(b.excluded))
+	prefs := make([]WeightedPriority, 0, len(b.prefs)+len(b.excluded)+1)
+	for _, typ := range b.excluded {
+		h, err := newMedia(stripQuality(typ))
+		if err != nil {
+			continue
+		}
+		excluded[h.NormalizedValue] = true
+	}
+
+	catchAll := false
+	for _, p := range b.prefs {
+		h, err := newMedia(stripQuality(p.Value))
+		if err != nil || excluded[h.NormalizedValue] {
+			continue
+		}
+		catchAll = catchAll || h.Type == "*/*"
+		prefs = append(prefs, p)
+	}
+
+	if len(excluded) > 0 && !catchAll && !excluded["*/*"] {
+		prefs = append(prefs, WeightedPriority{Value: "*/*", Weight: 1})
+	}
+	for _, typ := range b.excluded {
+		prefs = append(prefs, WeightedPriority{Value: typ, Weight: 0})
+	}
+
+	return BuildAcceptHeader(prefs)
+}
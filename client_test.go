@@ -0,0 +1,172 @@
+package negotiation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildAcceptHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefs    []WeightedPriority
+		expected string
+	}{
+		{
+			name:     "sorted by weight",
+			prefs:    []WeightedPriority{{Value: "text/html", Weight: 0.5}, {Value: "application/json", Weight: 1}},
+			expected: "application/json, text/html; q=0.5",
+		},
+		{
+			name:     "ties keep order",
+			prefs:    []WeightedPriority{{Value: "text/html", Weight: 0.8}, {Value: "text/plain", Weight: 0.8}},
+			expected: "text/html; q=0.8, text/plain; q=0.8",
+		},
+		{
+			name:     "canonical element form",
+			prefs:    []WeightedPriority{{Value: " TEXT/HTML ;  z=y;a=b ", Weight: 1}},
+			expected: "text/html; a=b; z=y",
+		},
+		{
+			name:     "q in value is replaced by weight",
+			prefs:    []WeightedPriority{{Value: "text/html;q=0.1", Weight: 0.9}},
+			expected: "text/html; q=0.9",
+		},
+		{
+			name:     "weights clamped and rounded",
+			prefs:    []WeightedPriority{{Value: "a/a", Weight: 2}, {Value: "b/b", Weight: 0.12345}, {Value: "c/c", Weight: -1}},
+			expected: "a/a, b/b; q=0.123, c/c; q=0",
+		},
+		{
+			name:     "quoted parameter values",
+			prefs:    []WeightedPriority{{Value: `text/plain; title="a, b"`, Weight: 1}},
+			expected: `text/plain; title="a, b"`,
+		},
+		{
+			name:     "duplicates collapsed to highest weight",
+			prefs:    []WeightedPriority{{Value: "text/html", Weight: 0.2}, {Value: "application/json", Weight: 0.5}, {Value: "text/html", Weight: 0.9}},
+			expected: "text/html; q=0.9, application/json; q=0.5",
+		},
+		{
+			name:     "invalid preferences skipped",
+			prefs:    []WeightedPriority{{Value: "invalid", Weight: 1}, {Value: "*/*", Weight: 0.1}},
+			expected: "*/*; q=0.1",
+		},
+		{
+			name:     "no preferences",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := BuildAcceptHeader(tt.prefs)
+			assert.Equal(t, tt.expected, header)
+
+			if header == "" {
+				return
+			}
+
+			// Round-trips through the server's normalization.
+			normalized, err := NewMediaNegotiator().Normalize(header)
+			require.NoError(t, err)
+			assert.Equal(t, header, normalized)
+		})
+	}
+}
+
+func TestBuildAcceptHeader_Negotiate(t *testing.T) {
+	header := BuildAcceptHeader([]WeightedPriority{
+		{Value: "application/xml", Weight: 0.5},
+		{Value: "application/json", Weight: 1},
+	})
+
+	result, err := NewMediaNegotiator().Negotiate(header, []string{"application/xml", "application/json"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", result.Value)
+}
+
+func TestAcceptBuilder(t *testing.T) {
+	tests := []struct {
+		name     string
+		build    func(b *AcceptBuilder)
+		expected string
+	}{
+		{
+			name:     "exclusion adds catch-all",
+			build:    func(b *AcceptBuilder) { b.Exclude("application/xml") },
+			expected: "*/*, application/xml; q=0",
+		},
+		{
+			name:     "existing catch-all kept",
+			build:    func(b *AcceptBuilder) { b.Add("application/json", 1).Add("*/*", 0.1).Exclude("application/xml") },
+			expected: "application/json, */*; q=0.1, application/xml; q=0",
+		},
+		{
+			name:     "several exclusions",
+			build:    func(b *AcceptBuilder) { b.Exclude("application/xml").Exclude("Text/CSV") },
+			expected: "*/*, application/xml; q=0, text/csv; q=0",
+		},
+		{
+			name: "exclusion wins over addition",
+			build: func(b *AcceptBuilder) {
+				b.Add("application/xml", 0.9).Add("text/html", 1).Exclude("application/xml;q=0.5")
+			},
+			expected: "text/html, */*, application/xml; q=0",
+		},
+		{
+			name:     "excluded range",
+			build:    func(b *AcceptBuilder) { b.Exclude("image/*") },
+			expected: "*/*, image/*; q=0",
+		},
+		{
+			name:     "excluding everything",
+			build:    func(b *AcceptBuilder) { b.Add("*/*", 1).Exclude("*/*") },
+			expected: "*/*; q=0",
+		},
+		{
+			name:     "invalid exclusion skipped",
+			build:    func(b *AcceptBuilder) { b.Add("text/html", 1).Exclude("invalid") },
+			expected: "text/html",
+		},
+		{
+			name:     "additions only",
+			build:    func(b *AcceptBuilder) { b.Add("text/html", 0.5).Add("application/json", 1) },
+			expected: "application/json, text/html; q=0.5",
+		},
+		{
+			name:     "empty",
+			build:    func(*AcceptBuilder) {},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewAcceptBuilder()
+			tt.build(b)
+			assert.Equal(t, tt.expected, b.Build())
+		})
+	}
+}
+
+func TestAcceptBuilder_ExclusionRoundTrip(t *testing.T) {
+	header := NewAcceptBuilder().Add("application/json", 1).Exclude("application/xml").Build()
+	negotiator := NewMediaNegotiator()
+
+	normalized, err := negotiator.Normalize(header)
+	require.NoError(t, err)
+	assert.Equal(t, header, normalized)
+
+	rejected, err := negotiator.Rejected(header, []string{"application/xml", "text/html", "application/json"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"application/xml"}, rejected)
+
+	_, err = negotiator.Negotiate(header, []string{"application/xml"}, true)
+	require.ErrorIs(t, err, ErrNoAcceptableMatch)
+
+	best, err := negotiator.Negotiate(header, []string{"application/xml", "text/html"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "text/html", best.Type)
+}
@@ -0,0 +1,24 @@
+package negotiation
+
+import "strings"
+
+// ParseMediaTypeCompat parses a media type like mime.ParseMediaType: it returns the
+// lowercased type and the parameters with lowercased names and their values as
+// given, unquoted. As with mime.ParseMediaType a q parameter is returned as an
+// ordinary parameter. Unlike mime.ParseMediaType a subtype is required, and "*"
+// is returned as "*/*".
+func ParseMediaTypeCompat(v string) (mediatype string, params map[string]string, err error) {
+	h, err := newMedia(stripQuality(v))
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, part := range splitParameters(v)[1:] {
+		key, val, _ := strings.Cut(part, "=")
+		if isQualityParam(key) {
+			h.Parameters["q"] = unquoteValue(strings.TrimSpace(val))
+		}
+	}
+
+	return h.Type, h.Parameters, nil
+}
@@ -0,0 +1,68 @@
+package negotiation
+
+import (
+	"mime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMediaTypeCompat_MatchesStdlib(t *testing.T) {
+	values := []string{
+		"text/html",
+		"TEXT/HTML",
+		"text/html; charset=UTF-8",
+		"Text/HTML; Charset=UTF-8; Level=1",
+		"multipart/form-data; boundary=AaBb--XyZ",
+		`text/plain; title="a;b"`,
+		`text/plain; title="a \"quoted\" word"`,
+		"application/json;q=0.5",
+		"application/json; Q=1",
+		"application/vnd.api+json; version=2",
+		"text/html ; level = 2",
+		"*/*",
+		"text/*;q=0.1",
+	}
+
+	for _, v := range values {
+		t.Run(v, func(t *testing.T) {
+			expectedType, expectedParams, err := mime.ParseMediaType(v)
+			require.NoError(t, err)
+
+			mediatype, params, err := ParseMediaTypeCompat(v)
+			require.NoError(t, err)
+			assert.Equal(t, expectedType, mediatype)
+			assert.Equal(t, expectedParams, params)
+		})
+	}
+}
+
+func TestParseMediaTypeCompat_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"empty", ""},
+		{"missing subtype", "text/"},
+		{"no subtype", "text"},
+		{"parameters only", ";charset=utf-8"},
+		{"invalid parameter name", `text/html; "a"=b`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mediatype, params, err := ParseMediaTypeCompat(tt.value)
+			require.Error(t, err)
+			assert.Empty(t, mediatype)
+			assert.Nil(t, params)
+		})
+	}
+}
+
+func TestParseMediaTypeCompat_Wildcard(t *testing.T) {
+	mediatype, params, err := ParseMediaTypeCompat("*")
+	require.NoError(t, err)
+	assert.Equal(t, "*/*", mediatype)
+	assert.Empty(t, params)
+}
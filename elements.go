@@ -0,0 +1,154 @@
+package negotiation
+
+import (
+	"container/heap"
+	"iter"
+	"strings"
+)
+
+// Elements returns an iterator over the header elements in the order of GetOrderedElements.
+// Elements are yielded lazily from a heap, so stopping early avoids sorting the
+// whole header. If the header is empty and the negotiator has no empty header
+// default, a single (nil, error) pair is yielded.
+func (c *Negotiator) Elements(header string) iter.Seq2[*Header, error] {
+	return func(yield func(*Header, error) bool) {
+		elements, err := c.parseAcceptHeaders(header, false)
+		if err != nil {
+			yield(nil, err)
+
+			return
+		}
+
+		c.restoreCase(elements...)
+
+		compare := c.comparator
+		if compare == nil {
+			compare = DefaultComparator
+		}
+
+		h := &elementHeap{elements: elements, compare: compare}
+		heap.Init(h)
+
+		for h.Len() > 0 {
+			e, _ := heap.Pop(h).(*Header)
+			if !yield(e, nil) {
+				return
+			}
+		}
+	}
+}
+
+// ParseElements parses the header element by element in header order, calling fn
+// for each valid element until fn returns false. Unlike GetOrderedElements it builds
+// no slice of elements, so large headers can be inspected with early termination.
+// Invalid elements are skipped as by GetOrderedElements, but duplicates are not
+// collapsed and elements are not ordered by quality. The only error is ErrEmptyHeader
+// for an empty header without an empty header default.
+func (c *Negotiator) ParseElements(header string, fn func(*Header) bool) error {
+	resolved, err := c.resolveEmptyHeader(header)
+	if err != nil {
+		return err
+	}
+	substituted := resolved != header
+
+	i := 0
+	scanHeader(resolved, c.opts.commaDecimalTolerance, func(part headerPart) bool {
+		h, err := c.parsePart(part, i, false, substituted, func(error) {})
+		i++
+		if err != nil {
+			return true
+		}
+		c.restoreCase(h)
+
+		return fn(h)
+	})
+
+	return nil
+}
+
+// Preferred returns the element the client prefers most: the first element of
+// GetOrderedElements, found in a single pass without building or sorting the
+// elements. Returns ErrEmptyHeader for an empty header without an empty header
+// default and InvalidHeaderError if the header has no valid element.
+func (c *Negotiator) Preferred(header string) (*Header, error) {
+	compare := c.comparator
+	if compare == nil {
+		compare = DefaultComparator
+	}
+
+	var best *Header
+	err := c.ParseElements(header, func(h *Header) bool {
+		if best == nil || compare(h, best) < 0 {
+			best = h
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if best == nil {
+		return nil, &InvalidHeaderError{Header: header}
+	}
+
+	return best, nil
+}
+
+// AcceptedTypes returns the distinct types the client explicitly accepts, in the
+// order of GetOrderedElements: wildcards and ranges such as "text/*" and elements
+// with q=0 are dropped, and parameters are ignored, so "text/html;level=1, text/html"
+// yields "text/html" once. Types are lowercased, even under WithPreserveCase.
+// It is meant for telemetry on the formats clients
+// actually request. Errors are those of GetOrderedElements.
+func (c *Negotiator) AcceptedTypes(header string) ([]string, error) {
+	elements, err := c.GetOrderedElements(header)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(elements))
+	types := make([]string, 0, len(elements))
+	for _, e := range elements {
+		key := strings.ToLower(e.Type)
+		if e.Quality <= 0 || strings.Contains(e.Type, "*") || seen[key] {
+			continue
+		}
+		seen[key] = true
+		types = append(types, key)
+	}
+
+	return types, nil
+}
+
+// elementHeap is a heap of header elements ordered by a comparator,
+// falling back to the original index so equal elements keep their order.
+type elementHeap struct {
+	elements []*Header
+	compare  func(a, b *Header) int
+}
+
+func (h *elementHeap) Len() int { return len(h.elements) }
+
+func (h *elementHeap) Less(i, j int) bool {
+	a, b := h.elements[i], h.elements[j]
+	if c := h.compare(a, b); c != 0 {
+		return c < 0
+	}
+
+	return a.originalIndex < b.originalIndex
+}
+
+func (h *elementHeap) Swap(i, j int) { h.elements[i], h.elements[j] = h.elements[j], h.elements[i] }
+
+func (h *elementHeap) Push(x any) {
+	e, _ := x.(*Header)
+	h.elements = append(h.elements, e)
+}
+
+func (h *elementHeap) Pop() any {
+	n := len(h.elements)
+	e := h.elements[n-1]
+	h.elements = h.elements[:n-1]
+
+	return e
+}
@@ -0,0 +1,279 @@
+package negotiation
+
+import (
+	"cmp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiator_Elements(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+	header := "text/html;q=0.3, application/json;q=0.9, text/plain, image/png;q=0.9, */*;q=0.1"
+
+	var types []string
+	for e, err := range negotiator.Elements(header) {
+		require.NoError(t, err)
+		types = append(types, e.Type)
+	}
+
+	assert.Equal(t, []string{"text/plain", "application/json", "image/png", "text/html", "*/*"}, types)
+
+	// Same order as GetOrderedElements.
+	ordered, err := negotiator.GetOrderedElements(header)
+	require.NoError(t, err)
+	for i, e := range ordered {
+		assert.Equal(t, e.Type, types[i])
+	}
+}
+
+func TestNegotiator_Elements_StopEarly(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	var top *Header
+	for e, err := range negotiator.Elements("text/html;q=0.3, application/json, text/plain;q=0.5") {
+		require.NoError(t, err)
+		top = e
+
+		break
+	}
+
+	require.NotNil(t, top)
+	assert.Equal(t, "application/json", top.Type)
+}
+
+func TestNegotiator_Elements_CustomComparator(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+	negotiator.SetComparator(func(_, _ *Header) int { return 0 })
+
+	var types []string
+	for e, err := range negotiator.Elements("a/a;q=0.1, b/b, c/c;q=0.5") {
+		require.NoError(t, err)
+		types = append(types, e.Type)
+	}
+
+	assert.Equal(t, []string{"a/a", "b/b", "c/c"}, types)
+}
+
+func TestNegotiator_Elements_EmptyHeader(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	count := 0
+	for e, err := range negotiator.Elements("") {
+		count++
+		assert.Nil(t, e)
+		require.ErrorIs(t, err, ErrEmptyHeader)
+	}
+
+	assert.Equal(t, 1, count)
+}
+
+func TestNegotiator_ParseElements(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+	header := "text/html;q=0.3, invalid, application/json;q=0.9, text/html;q=0.5, */*;q=0.1"
+
+	var values []string
+	err := negotiator.ParseElements(header, func(e *Header) bool {
+		values = append(values, e.Value)
+		assert.Equal(t, e.Value, header[e.Start:e.End])
+
+		return true
+	})
+	require.NoError(t, err)
+
+	// Header order, invalid elements skipped, duplicates kept.
+	assert.Equal(t, []string{"text/html;q=0.3", "application/json;q=0.9", "text/html;q=0.5", "*/*;q=0.1"}, values)
+}
+
+func TestNegotiator_ParseElements_SameElementsAsGetOrderedElements(t *testing.T) {
+	negotiator := NewLanguageNegotiator()
+	header := "fr;q=0.5, en-US, de;q=bogus, *;q=0.1"
+
+	var streamed []*Header
+	require.NoError(t, negotiator.ParseElements(header, func(e *Header) bool {
+		streamed = append(streamed, e)
+
+		return true
+	}))
+
+	ordered, err := negotiator.GetOrderedElements(header)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, ordered, streamed)
+}
+
+func TestNegotiator_ParseElements_StopEarly(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	calls := 0
+	err := negotiator.ParseElements("text/html, application/json, text/plain", func(_ *Header) bool {
+		calls++
+
+		return calls < 2
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+}
+
+func TestNegotiator_ParseElements_EmptyHeader(t *testing.T) {
+	called := false
+	fn := func(_ *Header) bool {
+		called = true
+
+		return true
+	}
+
+	err := NewMediaNegotiator().ParseElements("  ", fn)
+	require.ErrorIs(t, err, ErrEmptyHeader)
+	assert.False(t, called)
+
+	// Only separators: nothing to yield.
+	require.NoError(t, NewMediaNegotiator().ParseElements(" , ,", fn))
+	assert.False(t, called)
+
+	// The empty header default is parsed instead, without offsets.
+	var encodings []*Header
+	require.NoError(t, NewEncodingNegotiator().ParseElements("", func(e *Header) bool {
+		encodings = append(encodings, e)
+
+		return true
+	}))
+	require.Len(t, encodings, 1)
+	assert.Equal(t, "identity", encodings[0].Type)
+	assert.Zero(t, encodings[0].End)
+}
+
+func BenchmarkParseElements_First(b *testing.B) {
+	negotiator := NewMediaNegotiator()
+	header := strings.Repeat("application/vnd.example+json;version=2;q=0.5, ", 1000) + "*/*;q=0.1"
+
+	b.ReportAllocs()
+	for b.Loop() {
+		_ = negotiator.ParseElements(header, func(_ *Header) bool { return false })
+	}
+}
+
+func TestNegotiator_Preferred(t *testing.T) {
+	tests := []struct {
+		name       string
+		negotiator *Negotiator
+		header     string
+		expected   string
+	}{
+		{"highest quality", NewMediaNegotiator(), "text/html;q=0.3, application/json;q=0.9, text/plain;q=0.5", "application/json;q=0.9"},
+		{"earliest on ties", NewMediaNegotiator(), "text/html;q=0.5, application/json;q=0.5", "text/html;q=0.5"},
+		{"default quality", NewMediaNegotiator(), "text/html;q=0.9, application/xml", "application/xml"},
+		{"invalid elements skipped", NewMediaNegotiator(), "invalid, text/html;q=0.1", "text/html;q=0.1"},
+		{"language", NewLanguageNegotiator(), "en;q=0.8, fr-CA", "fr-CA"},
+		{"encoding empty header default", NewEncodingNegotiator(), "", "identity"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preferred, err := tt.negotiator.Preferred(tt.header)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, preferred.Value)
+
+			ordered, err := tt.negotiator.GetOrderedElements(tt.header)
+			require.NoError(t, err)
+			assert.Equal(t, ordered[0], preferred, "same as the first ordered element")
+		})
+	}
+}
+
+func TestNegotiator_Preferred_CustomComparator(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+	negotiator.SetComparator(func(a, b *Header) int {
+		return cmp.Compare(a.Type, b.Type)
+	})
+
+	preferred, err := negotiator.Preferred("text/html, application/json;q=0.1")
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", preferred.Type)
+}
+
+func TestNegotiator_Preferred_Errors(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	_, err := negotiator.Preferred("")
+	require.ErrorIs(t, err, ErrEmptyHeader)
+
+	_, err = negotiator.Preferred("invalid, bogus")
+	assert.IsType(t, &InvalidHeaderError{}, err)
+}
+
+func TestNegotiator_AcceptedTypes(t *testing.T) {
+	tests := []struct {
+		name       string
+		negotiator *Negotiator
+		header     string
+		expected   []string
+	}{
+		{
+			name:       "quality order",
+			negotiator: NewMediaNegotiator(),
+			header:     "text/html;q=0.5, application/json, application/xml;q=0.9",
+			expected:   []string{"application/json", "application/xml", "text/html"},
+		},
+		{
+			name:       "wildcards and ranges dropped",
+			negotiator: NewMediaNegotiator(),
+			header:     "text/*, application/*+json, */*;q=0.1, image/png;q=0.8",
+			expected:   []string{"image/png"},
+		},
+		{
+			name:       "zero quality dropped",
+			negotiator: NewMediaNegotiator(),
+			header:     "application/xml;q=0, application/json",
+			expected:   []string{"application/json"},
+		},
+		{
+			name:       "parameters ignored and types distinct",
+			negotiator: NewMediaNegotiator(),
+			header:     "Text/HTML;level=1, text/html;q=0.4, text/plain;q=0.5",
+			expected:   []string{"text/html", "text/plain"},
+		},
+		{
+			name:       "normalized under preserve case",
+			negotiator: NewMediaNegotiator(WithPreserveCase(true)),
+			header:     "Text/HTML, Application/JSON;q=0.5",
+			expected:   []string{"text/html", "application/json"},
+		},
+		{
+			name:       "invalid elements skipped",
+			negotiator: NewMediaNegotiator(),
+			header:     "invalid, application/json",
+			expected:   []string{"application/json"},
+		},
+		{
+			name:       "only wildcards",
+			negotiator: NewMediaNegotiator(),
+			header:     "*/*",
+			expected:   []string{},
+		},
+		{
+			name:       "languages",
+			negotiator: NewLanguageNegotiator(),
+			header:     "de-CH, *;q=0.5, fr;q=0.8, en;q=0",
+			expected:   []string{"de-ch", "fr"},
+		},
+		{
+			name:       "encodings",
+			negotiator: NewEncodingNegotiator(),
+			header:     "gzip;q=0.5, br, *;q=0.1",
+			expected:   []string{"br", "gzip"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			types, err := tt.negotiator.AcceptedTypes(tt.header)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, types)
+		})
+	}
+
+	_, err := NewMediaNegotiator().AcceptedTypes("")
+	require.ErrorIs(t, err, ErrEmptyHeader)
+}
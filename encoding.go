@@ -0,0 +1,24 @@
+package negotiation
+
+// identityCoding is the content coding meaning no transformation.
+const identityCoding = "identity"
+
+// BestEncoding returns the best content coding from available for an
+// Accept-Encoding header, applying the rules of RFC 7231, section 5.3.4:
+// a coding with q=0 is never chosen, "*" matches codings not listed, and the
+// identity coding is acceptable unless excluded by "identity;q=0" or by "*;q=0"
+// without an identity entry. When none of the available codings is acceptable,
+// "identity" is returned if acceptable, otherwise ErrNoAcceptableMatch.
+// An empty header means identity only.
+func BestEncoding(header string, available []string) (string, error) {
+	if len(available) == 0 {
+		available = []string{identityCoding}
+	}
+
+	best, err := NewEncodingNegotiator(WithImplicitIdentity(true)).Negotiate(header, available, false)
+	if err != nil {
+		return "", err
+	}
+
+	return best.Value, nil
+}
@@ -0,0 +1,150 @@
+package negotiation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBestEncoding(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		available []string
+		expected  string
+		expectErr error
+	}{
+		{"preferred coding", "br;q=1.0, gzip;q=0.9, *;q=0", []string{"gzip", "br", "zstd"}, "br", nil},
+		{"available list lacking br", "br;q=1.0, gzip;q=0.9, *;q=0", []string{"zstd", "gzip"}, "gzip", nil},
+		{"wildcard excludes identity", "br;q=1.0, gzip;q=0.9, *;q=0", []string{"zstd"}, "", ErrNoAcceptableMatch},
+		{"wildcard excludes identity but identity listed", "br, identity;q=0.1, *;q=0", []string{"zstd"}, "identity", nil},
+		{"identity fallback", "br, gzip", []string{"zstd", "deflate"}, "identity", nil},
+		{"identity excluded", "br, identity;q=0", []string{"zstd"}, "", ErrNoAcceptableMatch},
+		{"rejected coding", "zstd;q=0, gzip;q=0.5", []string{"zstd", "gzip"}, "gzip", nil},
+		{"wildcard accepts unlisted coding", "br;q=0.5, *", []string{"br", "zstd"}, "zstd", nil},
+		{"case insensitive", "ZSTD, GZIP;q=0.8", []string{"gzip", "zstd"}, "zstd", nil},
+		{"empty header means identity", "", []string{"br", "gzip"}, "identity", nil},
+		{"nothing available", "br", nil, "identity", nil},
+		{"identity available", "br", []string{"gzip", "identity"}, "identity", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			best, err := BestEncoding(tt.header, tt.available)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+				assert.Empty(t, best)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, best)
+		})
+	}
+}
+
+func TestEncodingNegotiator_ImplicitIdentity(t *testing.T) {
+	tests := []struct {
+		name       string
+		implicit   bool
+		header     string
+		priorities []string
+		expected   string
+		via        MatchKind
+	}{
+		{"explicit coding wins over listed identity", true, "gzip", []string{"identity", "gzip"}, "gzip", MatchExact},
+		{"explicit coding wins without option", false, "gzip", []string{"identity", "gzip"}, "gzip", MatchExact},
+		{"listed identity is implicitly acceptable", true, "br", []string{"gzip", "identity"}, "identity", MatchImplicit},
+		{"listed identity needs the option", false, "br", []string{"gzip", "identity"}, "", ""},
+		{"identity is appended", true, "br", []string{"gzip"}, "identity", MatchImplicit},
+		{"identity is not appended without option", false, "br", []string{"gzip"}, "", ""},
+		{"client identity quality applies", true, "br, identity;q=0.5", []string{"gzip"}, "identity", MatchExact},
+		{"client identity preferred by quality", true, "gzip;q=0.5, identity", []string{"gzip", "identity"}, "identity", MatchExact},
+		{"identity excluded", true, "br, identity;q=0", []string{"gzip", "identity"}, "", ""},
+		{"wildcard excludes identity", true, "br, *;q=0", []string{"gzip"}, "", ""},
+		{"wildcard accepts identity", true, "br, *;q=0.1", []string{"identity"}, "identity", MatchFullWildcard},
+		{"identity overrides excluding wildcard", true, "br, identity;q=0.1, *;q=0", []string{"gzip"}, "identity", MatchExact},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			negotiator := NewEncodingNegotiator(WithImplicitIdentity(tt.implicit))
+
+			best, err := negotiator.Negotiate(tt.header, tt.priorities, true)
+			if tt.expected == "" {
+				require.ErrorIs(t, err, ErrNoAcceptableMatch)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, best.Value)
+			assert.Equal(t, tt.via, best.MatchedVia)
+		})
+	}
+}
+
+func TestEncodingNegotiator_ImplicitIdentityMatch(t *testing.T) {
+	negotiator := NewEncodingNegotiator(WithImplicitIdentity(true), WithPreserveCase(true))
+
+	match, err := negotiator.NegotiateMatch("br", []string{"gzip"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "identity", match.Priority)
+	assert.Nil(t, match.ClientElement)
+
+	_, err = NewEncodingNegotiator(WithImplicitIdentity(true), WithAllowlist([]string{"gzip"})).Negotiate("br", []string{"gzip"}, false)
+	require.ErrorIs(t, err, ErrNoAcceptableMatch)
+}
+
+func TestEncodingNegotiator_ImplicitIdentityOnlyPicksWinners(t *testing.T) {
+	negotiator := NewEncodingNegotiator(WithImplicitIdentity(true))
+
+	ok, err := negotiator.Acceptable("gzip", "br", false)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	q, err := negotiator.QualityOf("gzip", "br", false)
+	require.NoError(t, err)
+	assert.Zero(t, q)
+
+	ok, err = negotiator.StillAcceptable("gzip", "br", false)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	rejected, err := negotiator.Rejected("gzip", []string{"br", "gzip"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"br"}, rejected)
+
+	// Negotiation itself still falls back to identity
+	best, err := negotiator.Negotiate("gzip", []string{"br"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "identity", best.Value)
+}
+
+func TestEncodingNegotiator_Parameters(t *testing.T) {
+	header := "gzip;q=0.9;foo=bar, br;q=1.0;level=5"
+
+	for _, strict := range []bool{true, false} {
+		best, err := NewEncodingNegotiator().Negotiate(header, []string{"gzip", "br"}, strict)
+		require.NoError(t, err)
+		assert.Equal(t, "br", best.Type)
+	}
+
+	elements, err := NewEncodingNegotiator().GetOrderedElements(header)
+	require.NoError(t, err)
+	require.Len(t, elements, 2)
+	assert.Equal(t, "br", elements[0].Type)
+	assert.Equal(t, map[string]string{"level": "5"}, elements[0].Parameters)
+	assert.Equal(t, "gzip", elements[1].Type)
+	assert.Equal(t, map[string]string{"foo": "bar"}, elements[1].Parameters)
+
+	coding, err := BestEncoding(header, []string{"gzip"})
+	require.NoError(t, err)
+	assert.Equal(t, "gzip", coding)
+
+	charset, err := NewCharsetNegotiator().Negotiate("utf8;foo=bar, iso-8859-1;q=0.5", []string{"iso-8859-1", "utf-8"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "utf-8", charset.Type)
+}
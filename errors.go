@@ -1,6 +1,9 @@
 package negotiation
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 // InvalidArgumentError is returned when an invalid argument is provided.
 type InvalidArgumentError struct {
@@ -11,8 +14,56 @@ func (e *InvalidArgumentError) Error() string {
 	return e.Message
 }
 
+// headerError is embedded in parse errors to mark those of the negotiated header,
+// as opposed to those of server priorities, so that they match ErrMalformedHeader.
+type headerError struct {
+	inHeader bool
+}
+
+// Is reports whether the error is a parse error of the negotiated header
+// and target is ErrMalformedHeader.
+func (e *headerError) Is(target error) bool {
+	return e.inHeader && target == ErrMalformedHeader
+}
+
+func (e *headerError) markHeader() {
+	e.inHeader = true
+}
+
+// markHeaderError marks err as a parse error of the negotiated header. Errors
+// without an embedded headerError, such as those of the parse function of a
+// generic negotiator, are wrapped in a headerParseError.
+func markHeaderError(err error) error {
+	var marker interface{ markHeader() }
+	if !errors.As(err, &marker) {
+		return &headerParseError{headerError: headerError{inHeader: true}, err: err}
+	}
+	marker.markHeader()
+
+	return err
+}
+
+// headerParseError wraps a parse error of the negotiated header that does not
+// embed headerError, so that it matches ErrMalformedHeader as well as the
+// wrapped error.
+type headerParseError struct {
+	headerError
+
+	err error
+}
+
+func (e *headerParseError) Error() string {
+	return e.err.Error()
+}
+
+func (e *headerParseError) Unwrap() error {
+	return e.err
+}
+
 // InvalidHeaderError is returned when a header cannot be parsed.
 type InvalidHeaderError struct {
+	headerError
+
 	Header string
 }
 
@@ -20,19 +71,84 @@ func (e *InvalidHeaderError) Error() string {
 	return fmt.Sprintf("failed to parse accept header: %q", e.Header)
 }
 
+// InvalidQualityError is returned in strict mode when the q parameter of an
+// element is not a valid quality value.
+type InvalidQualityError struct {
+	headerError
+
+	// Header is the element containing the q parameter.
+	Header string
+	// Quality is the malformed q value.
+	Quality string
+}
+
+func (e *InvalidQualityError) Error() string {
+	return fmt.Sprintf("invalid quality value %q in %q", e.Quality, e.Header)
+}
+
+// TooManyParametersError is returned when a header element has more parameters
+// than the negotiator allows, see SetMaxParameters.
+type TooManyParametersError struct {
+	headerError
+
+	// Header is the element with too many parameters.
+	Header string
+	// Max is the parameter limit.
+	Max int
+}
+
+func (e *TooManyParametersError) Error() string {
+	return fmt.Sprintf("more than %d parameters in %q", e.Max, e.Header)
+}
+
 // InvalidMediaTypeError is returned when a media type is invalid.
-type InvalidMediaTypeError struct{}
+type InvalidMediaTypeError struct {
+	headerError
+}
 
 func (e *InvalidMediaTypeError) Error() string {
 	return "invalid media type"
 }
 
 // InvalidLanguageError is returned when a language tag is invalid.
-type InvalidLanguageError struct{}
+type InvalidLanguageError struct {
+	headerError
+}
 
 func (e *InvalidLanguageError) Error() string {
 	return "invalid language"
 }
 
-// ErrNoMatch is returned when no matching header is found.
-var ErrNoMatch = &InvalidArgumentError{Message: "no matching header found"}
+var (
+	// ErrEmptyPriorities is returned when no server priorities are given.
+	// It usually indicates a programming error rather than a client problem.
+	ErrEmptyPriorities = &InvalidArgumentError{Message: "a set of server priorities should be given"}
+
+	// ErrEmptyHeader is returned when the header string to negotiate is empty.
+	ErrEmptyHeader = &InvalidArgumentError{Message: "the header string should not be empty"}
+
+	// ErrMalformedHeader is matched by the parse errors of a malformed client header
+	// returned in strict mode (InvalidHeaderError, InvalidQualityError,
+	// InvalidMediaTypeError, InvalidLanguageError and the errors of the parse
+	// function of a generic negotiator), typically answered with 400 Bad Request.
+	// Parse errors of server priorities never match it.
+	ErrMalformedHeader = errors.New("malformed header")
+
+	// ErrNoAcceptableMatch is returned when none of the server priorities is
+	// acceptable to the client (typically answered with 406 Not Acceptable).
+	// It is an *InvalidArgumentError, as ErrNoMatch has always been.
+	ErrNoAcceptableMatch = &InvalidArgumentError{Message: "no matching header found"}
+
+	// ErrWildcardOnly is returned when the client accepts nothing but full
+	// wildcards and wildcard-only clients are rejected, see WithRejectWildcardOnly.
+	ErrWildcardOnly = errors.New("client accepts only wildcards")
+
+	// ErrNotAllowed is reported by ValidatePriorities for priorities whose type
+	// is not in the allowlist, see WithAllowlist.
+	ErrNotAllowed = errors.New("type is not in the allowlist")
+
+	// ErrNoMatch is returned when no matching header is found.
+	//
+	// Deprecated: use ErrNoAcceptableMatch.
+	ErrNoMatch = ErrNoAcceptableMatch
+)
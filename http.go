@@ -0,0 +1,354 @@
+package negotiation
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Dimension pairs a negotiator with the server priorities for one request header.
+type Dimension struct {
+	// Negotiator negotiates the dimension. Nil uses the default negotiator for the header.
+	Negotiator *Negotiator
+	// Priorities are the server priorities. A dimension without priorities is skipped.
+	Priorities []string
+}
+
+// NegotiationSpec describes the dimensions negotiated together by NegotiateAll.
+type NegotiationSpec struct {
+	Media    Dimension
+	Language Dimension
+	Charset  Dimension
+	Encoding Dimension
+	// Strict is passed to every Negotiate call.
+	Strict bool
+}
+
+// Result is the outcome of NegotiateAll.
+// Fields are nil for dimensions that were not negotiated.
+type Result struct {
+	MediaType *Header
+	Language  *Header
+	Charset   *Header
+	Encoding  *Header
+	// Vary lists the request headers the result depends on, for the Vary response header.
+	Vary string
+}
+
+// NegotiateAll negotiates media type, language, charset and encoding of a request at once.
+// When the request lacks a header the client accepts anything, so the first
+// priority of that dimension is chosen. A header sent empty follows the empty
+// header rule of the negotiator, e.g. identity only for Accept-Encoding. Errors are prefixed with the header name
+// and wrap the underlying error, e.g. ErrNoAcceptableMatch.
+func NegotiateAll(r *http.Request, spec NegotiationSpec) (*Result, error) {
+	result := &Result{}
+	vary := make([]string, 0, 4)
+
+	dimensions := []struct {
+		header     string
+		dimension  Dimension
+		newDefault func(opts ...Option) *Negotiator
+		target     **Header
+	}{
+		{"Accept", spec.Media, NewMediaNegotiator, &result.MediaType},
+		{"Accept-Language", spec.Language, NewLanguageNegotiator, &result.Language},
+		{"Accept-Charset", spec.Charset, NewCharsetNegotiator, &result.Charset},
+		{"Accept-Encoding", spec.Encoding, NewEncodingNegotiator, &result.Encoding},
+	}
+
+	for _, d := range dimensions {
+		if len(d.dimension.Priorities) == 0 {
+			continue
+		}
+
+		negotiator := d.dimension.Negotiator
+		if negotiator == nil {
+			negotiator = d.newDefault()
+		}
+
+		best, err := negotiator.Negotiate(requestHeader(r.Header, d.header), d.dimension.Priorities, spec.Strict)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", d.header, err)
+		}
+
+		*d.target = best
+		vary = append(vary, d.header)
+	}
+
+	result.Vary = strings.Join(vary, ", ")
+
+	return result, nil
+}
+
+// requestHeader returns the values of the named request header joined into one
+// list, or "*" if the request lacks the header, as a client without it accepts
+// anything. A header sent empty stays empty and follows the empty header rule of
+// the negotiator, see WithEmptyHeader.
+func requestHeader(h http.Header, name string) string {
+	values := h.Values(name)
+	if len(values) == 0 {
+		return "*"
+	}
+
+	values = slices.DeleteFunc(slices.Clone(values), func(v string) bool {
+		return strings.TrimSpace(v) == ""
+	})
+
+	return strings.Join(values, ", ")
+}
+
+// negotiateRequestHeader negotiates a request header value, treating an absent
+// header, given as "", as accepting anything (the first priority).
+func negotiateRequestHeader(n *Negotiator, header string, priorities []string, strict bool) (*Header, error) {
+	if strings.TrimSpace(header) == "" {
+		header = "*"
+	}
+
+	return n.Negotiate(header, priorities, strict)
+}
+
+// NegotiateHeader negotiates the values of the negotiator's request header in h,
+// such as Accept for a media negotiator, like Negotiate, for code that has the
+// headers of a request but not the request itself. The header name is looked up
+// in canonical form and multiple values are joined into one list. As for
+// NegotiateAll, an absent header accepts anything, while a header sent empty
+// follows the empty header rule, see WithEmptyHeader.
+func (c *Negotiator) NegotiateHeader(h http.Header, priorities []string, strict bool) (*Header, error) {
+	return c.Negotiate(requestHeader(h, c.headerName), priorities, strict)
+}
+
+// NegotiateWithOverride negotiates the media type of a request, letting a query
+// parameter such as ?format=json override the Accept header. If the parameter maps
+// to a type in mapping (e.g. "json" to "application/json"), the priority matching that
+// type is returned, or ErrNoAcceptableMatch if there is none. Otherwise, including for
+// unknown parameter values, the Accept header is negotiated as by NegotiateAll.
+func NegotiateWithOverride(r *http.Request, paramName string, mapping map[string]string, priorities []string) (*Header, error) {
+	negotiator := NewMediaNegotiator()
+
+	if typ, ok := mapping[r.URL.Query().Get(paramName)]; ok {
+		return negotiator.Negotiate(typ, priorities, true)
+	}
+
+	return negotiator.Negotiate(requestHeader(r.Header, "Accept"), priorities, false)
+}
+
+// NegotiateLanguageRedirect negotiates the language of a request from its
+// Accept-Language header and reports where to redirect it, for sites serving each
+// language but the default under a language-prefixed URL. The first priority is
+// the default language. It returns urlFor of the negotiated language and true
+// when that language is not the default and the first segment of the request
+// path is not already one of the priorities, e.g. "/de/about". Otherwise,
+// including when negotiation fails, it returns "" and false and the request is
+// served as is. Responses depending on the result should vary on Accept-Language.
+func NegotiateLanguageRedirect(r *http.Request, priorities []string, urlFor func(lang string) string) (string, bool) {
+	if len(priorities) == 0 || hasLanguagePrefix(r.URL.Path, priorities) {
+		return "", false
+	}
+
+	best, err := NewLanguageNegotiator().Negotiate(requestHeader(r.Header, "Accept-Language"), priorities, false)
+	if err != nil || strings.EqualFold(best.Value, priorities[0]) {
+		return "", false
+	}
+
+	return urlFor(best.Value), true
+}
+
+// hasLanguagePrefix reports whether the first segment of path is one of the
+// languages, compared case-insensitively.
+func hasLanguagePrefix(path string, languages []string) bool {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	for _, lang := range languages {
+		if strings.EqualFold(segment, lang) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ApplyContentType negotiates the media type of the response from the Accept header
+// of r and sets it as Content-Type on w. Accept is added to the Vary header of w
+// whether or not negotiation succeeds. Returns the chosen priority as given.
+func ApplyContentType(w http.ResponseWriter, r *http.Request, priorities []string) (string, error) {
+	return applyNegotiated(w, r, NewMediaNegotiator(), "Accept", "Content-Type", priorities)
+}
+
+// ApplyContentLanguage negotiates the language of the response from the
+// Accept-Language header of r and sets it as Content-Language on w.
+// Accept-Language is added to the Vary header of w whether or not negotiation
+// succeeds. Returns the chosen priority as given.
+func ApplyContentLanguage(w http.ResponseWriter, r *http.Request, priorities []string) (string, error) {
+	return applyNegotiated(w, r, NewLanguageNegotiator(), "Accept-Language", "Content-Language", priorities)
+}
+
+// ApplyContentEncoding negotiates the content coding of the response from the
+// Accept-Encoding header of r following the rules of BestEncoding, and sets it as
+// Content-Encoding on w. Accept-Encoding is added to the Vary header of w whether
+// or not negotiation succeeds. Returns the chosen coding, or "identity", in which
+// case no Content-Encoding is set. Multiple Accept-Encoding values are joined into
+// one list. Unlike the other helpers, an absent Accept-Encoding header means
+// identity only rather than anything, as a client without it may not decode
+// any coding.
+func ApplyContentEncoding(w http.ResponseWriter, r *http.Request, available []string) (string, error) {
+	addVary(w.Header(), "Accept-Encoding")
+
+	header := ""
+	if len(r.Header.Values("Accept-Encoding")) > 0 {
+		header = requestHeader(r.Header, "Accept-Encoding")
+	}
+
+	coding, err := BestEncoding(header, available)
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.EqualFold(coding, identityCoding) {
+		w.Header().Set("Content-Encoding", coding)
+	}
+
+	return coding, nil
+}
+
+// applyNegotiated negotiates a request header and sets the chosen priority as
+// the given response header, recording the request header in Vary.
+func applyNegotiated(w http.ResponseWriter, r *http.Request, n *Negotiator, requestName, responseName string, priorities []string) (string, error) {
+	addVary(w.Header(), requestName)
+
+	best, err := n.Negotiate(requestHeader(r.Header, requestName), priorities, false)
+	if err != nil {
+		return "", err
+	}
+
+	w.Header().Set(responseName, best.Value)
+
+	return best.Value, nil
+}
+
+// addVary adds name to the Vary header unless it is already listed.
+func addVary(h http.Header, name string) {
+	for _, value := range h.Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			field = strings.TrimSpace(field)
+			if field == "*" || strings.EqualFold(field, name) {
+				return
+			}
+		}
+	}
+
+	h.Add("Vary", name)
+}
+
+// WriteNotAcceptable responds with 406 Not Acceptable and a plain text body
+// listing the available representations, one priority per line.
+func WriteNotAcceptable(w http.ResponseWriter, priorities []string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusNotAcceptable)
+
+	var b strings.Builder
+	for _, p := range priorities {
+		b.WriteString(p)
+		b.WriteByte('\n')
+	}
+
+	_, _ = w.Write([]byte(b.String()))
+}
+
+// Variant is a representation offered for agent-driven negotiation, see
+// WriteMultipleChoices.
+type Variant struct {
+	// Type is the media type of the variant, e.g. "text/html".
+	Type string
+	// Language is the language of the variant, e.g. "en"; empty if none.
+	Language string
+	// URI locates the variant, e.g. "/docs/intro.en.html".
+	URI string
+	// Quality is the source quality of the variant in [0, 1], rounded to three
+	// decimals; zero means the default of 1.
+	Quality float64
+}
+
+// WriteMultipleChoices responds with 300 Multiple Choices and a plain text body
+// listing the variants, one per line in the variant description syntax of the
+// Alternates header (RFC 2295), for agent-driven negotiation when the server
+// cannot choose a representation itself. Set the Alternates header, see
+// Alternates, and a Location for a preferred variant before calling it.
+func WriteMultipleChoices(w http.ResponseWriter, variants []Variant) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusMultipleChoices)
+
+	var b strings.Builder
+	for _, v := range variants {
+		writeVariant(&b, v)
+		b.WriteByte('\n')
+	}
+
+	_, _ = w.Write([]byte(b.String()))
+}
+
+// Alternates builds the Alternates response header value (RFC 2295) describing
+// the variants, e.g. `{"/intro.en.html" 1 {type text/html} {language en}}`.
+func Alternates(variants []Variant) string {
+	var b strings.Builder
+	for i, v := range variants {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		writeVariant(&b, v)
+	}
+
+	return b.String()
+}
+
+// writeVariant writes the RFC 2295 variant description of v with its source
+// quality, which the syntax requires, omitting empty attributes.
+func writeVariant(b *strings.Builder, v Variant) {
+	quality := 1.0
+	if v.Quality != 0 {
+		quality = math.Round(min(max(v.Quality, 0), 1)*1000) / 1000
+	}
+
+	b.WriteString("{\"")
+	for i := 0; i < len(v.URI); i++ {
+		if v.URI[i] == '"' || v.URI[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(v.URI[i])
+	}
+	b.WriteString("\" " + strconv.FormatFloat(quality, 'f', -1, 64))
+	if v.Type != "" {
+		b.WriteString(" {type " + v.Type + "}")
+	}
+	if v.Language != "" {
+		b.WriteString(" {language " + v.Language + "}")
+	}
+	b.WriteByte('}')
+}
+
+// AcceptPatch builds the Accept-Patch response header value advertising the
+// supported patch document media types.
+func AcceptPatch(supported []string) string {
+	return strings.Join(supported, ", ")
+}
+
+// PatchAcceptable reports whether the Content-Type of a PATCH request is one of the
+// supported patch formats. Supported types may be ranges such as application/*+json;
+// parameters of the content type (e.g. charset) are allowed, while parameters of
+// a supported type are required. An invalid supported type is a server error
+// as reported by ValidatePriorities, while a malformed content type is a client
+// error matching ErrMalformedHeader.
+func PatchAcceptable(contentType string, supported []string) (bool, error) {
+	negotiator := NewMediaNegotiator(WithExactParameterMatch(true))
+	if err := negotiator.ValidatePriorities(supported); err != nil {
+		return false, err
+	}
+	if _, err := negotiator.parseElement(contentType); err != nil {
+		return false, markHeaderError(err)
+	}
+
+	return negotiator.Acceptable(AcceptPatch(supported), contentType, true)
+}
@@ -0,0 +1,411 @@
+package negotiation
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateAll(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "text/html;q=0.5, application/json")
+	r.Header.Set("Accept-Language", "fr, en;q=0.8")
+	r.Header.Set("Accept-Encoding", "gzip, br;q=0.9")
+
+	result, err := NegotiateAll(r, NegotiationSpec{
+		Media:    Dimension{Priorities: []string{"text/html", "application/json"}},
+		Language: Dimension{Priorities: []string{"en", "fr"}},
+		Charset:  Dimension{Priorities: []string{"utf-8"}},
+		Encoding: Dimension{Priorities: []string{"br", "gzip"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "application/json", result.MediaType.Type)
+	assert.Equal(t, "fr", result.Language.Type)
+	assert.Equal(t, "utf-8", result.Charset.Type, "absent header accepts the first priority")
+	assert.Equal(t, "gzip", result.Encoding.Type)
+	assert.Equal(t, "Accept, Accept-Language, Accept-Charset, Accept-Encoding", result.Vary)
+}
+
+func TestNegotiateAll_SkipsDimensionsWithoutPriorities(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "application/json")
+
+	result, err := NegotiateAll(r, NegotiationSpec{
+		Media: Dimension{Priorities: []string{"application/json"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "application/json", result.MediaType.Type)
+	assert.Nil(t, result.Language)
+	assert.Nil(t, result.Charset)
+	assert.Nil(t, result.Encoding)
+	assert.Equal(t, "Accept", result.Vary)
+}
+
+func TestNegotiateAll_CustomNegotiator(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Language", "en-GB")
+
+	result, err := NegotiateAll(r, NegotiationSpec{
+		Language: Dimension{
+			Negotiator: NewLanguageNegotiator(WithLanguageFallback(true)),
+			Priorities: []string{"en-US"},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "en-us", result.Language.Type)
+}
+
+func TestNegotiateAll_NoAcceptableMatch(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "image/png")
+
+	result, err := NegotiateAll(r, NegotiationSpec{
+		Media: Dimension{Priorities: []string{"application/json"}},
+	})
+	require.ErrorIs(t, err, ErrNoAcceptableMatch)
+	assert.Contains(t, err.Error(), "Accept")
+	assert.Nil(t, result)
+}
+
+func TestNegotiateAll_AbsentAndEmptyHeaders(t *testing.T) {
+	spec := NegotiationSpec{
+		Encoding: Dimension{Priorities: []string{"gzip", "identity"}},
+	}
+
+	absent := httptest.NewRequest(http.MethodGet, "/", nil)
+	result, err := NegotiateAll(absent, spec)
+	require.NoError(t, err)
+	assert.Equal(t, "gzip", result.Encoding.Value, "an absent header accepts anything")
+
+	empty := httptest.NewRequest(http.MethodGet, "/", nil)
+	empty.Header["Accept-Encoding"] = []string{""}
+	result, err = NegotiateAll(empty, spec)
+	require.NoError(t, err)
+	assert.Equal(t, "identity", result.Encoding.Value, "an empty header means identity only")
+
+	_, err = NegotiateAll(empty, NegotiationSpec{Encoding: Dimension{Priorities: []string{"gzip"}}})
+	require.ErrorIs(t, err, ErrNoAcceptableMatch)
+
+	empty.Header["Accept"] = []string{" ", ""}
+	_, err = NegotiateAll(empty, NegotiationSpec{Media: Dimension{Priorities: []string{"text/html"}}})
+	require.ErrorIs(t, err, ErrEmptyHeader)
+
+	_, err = NegotiateAll(empty, NegotiationSpec{Media: Dimension{
+		Negotiator: NewMediaNegotiator(WithEmptyHeader("*/*")),
+		Priorities: []string{"text/html"},
+	}})
+	require.NoError(t, err)
+}
+
+func TestNegotiator_NegotiateHeader(t *testing.T) {
+	h := http.Header{}
+	h.Add("Accept", "text/html;q=0.5")
+	h.Add("Accept", "application/json")
+	h.Add("Accept-Language", "fr, en;q=0.8")
+	h["Accept-Encoding"] = []string{"gzip"}
+	h["x-accept-version"] = []string{"v2"}
+
+	result, err := NewMediaNegotiator().NegotiateHeader(h, []string{"text/html", "application/json"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", result.Value)
+
+	result, err = NewLanguageNegotiator().NegotiateHeader(h, []string{"en", "fr"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "fr", result.Value)
+
+	result, err = NewEncodingNegotiator().NegotiateHeader(h, []string{"br", "gzip"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "gzip", result.Value)
+
+	// The header name is canonicalized, so a non-canonical map key is absent
+	result, err = NewGenericNegotiator("X-Accept-Version", nil).NegotiateHeader(h, []string{"v1", "v2"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "v1", result.Value)
+
+	// An absent header accepts anything, an empty one follows the empty header rule
+	result, err = NewCharsetNegotiator().NegotiateHeader(h, []string{"utf-8"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "utf-8", result.Value)
+
+	h["Accept-Charset"] = []string{""}
+	_, err = NewCharsetNegotiator().NegotiateHeader(h, []string{"utf-8"}, false)
+	require.ErrorIs(t, err, ErrEmptyHeader)
+}
+
+func TestNegotiator_NegotiateHeader_AgreesWithNegotiateAll(t *testing.T) {
+	priorities := []string{"application/json", "text/html"}
+
+	for _, accept := range [][]string{nil, {"text/html"}, {"text/html;q=0.5", "application/json;q=0.4"}} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		if accept != nil {
+			r.Header["Accept"] = accept
+		}
+
+		all, err := NegotiateAll(r, NegotiationSpec{Media: Dimension{Priorities: priorities}})
+		require.NoError(t, err)
+
+		best, err := NewMediaNegotiator().NegotiateHeader(r.Header, priorities, false)
+		require.NoError(t, err)
+		assert.Equal(t, all.MediaType.Value, best.Value, "Accept: %q", accept)
+	}
+}
+
+func TestWriteNotAcceptable(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	WriteNotAcceptable(w, []string{"application/json", "text/html"})
+
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "application/json\ntext/html\n", w.Body.String())
+}
+
+func TestWriteMultipleChoices(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	WriteMultipleChoices(w, []Variant{
+		{Type: "text/html", Language: "en", URI: "/intro.en.html"},
+		{Type: "application/pdf", URI: "/intro.pdf"},
+	})
+
+	assert.Equal(t, http.StatusMultipleChoices, w.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Empty(t, w.Header().Get("Alternates"))
+	assert.Equal(t, "{\"/intro.en.html\" 1 {type text/html} {language en}}\n{\"/intro.pdf\" 1 {type application/pdf}}\n", w.Body.String())
+}
+
+func TestAlternates(t *testing.T) {
+	assert.Equal(t,
+		`{"/intro.en.html" 1 {type text/html} {language en}}, {"/intro.de.html" 0.9 {language de}}`,
+		Alternates([]Variant{
+			{Type: "text/html", Language: "en", URI: "/intro.en.html"},
+			{Language: "de", URI: "/intro.de.html", Quality: 0.9},
+		}),
+	)
+	assert.Equal(t, `{"/a\"b\\c" 1}`, Alternates([]Variant{{URI: `/a"b\c`}}))
+	assert.Equal(t, `{"/a" 0.333}, {"/b" 1}`, Alternates([]Variant{{URI: "/a", Quality: 0.33333}, {URI: "/b", Quality: 2}}))
+	assert.Empty(t, Alternates(nil))
+}
+
+func TestAcceptPatch(t *testing.T) {
+	assert.Equal(t,
+		"application/json-patch+json, application/merge-patch+json",
+		AcceptPatch([]string{"application/json-patch+json", "application/merge-patch+json"}),
+	)
+}
+
+func TestPatchAcceptable(t *testing.T) {
+	supported := []string{"application/json-patch+json", "application/merge-patch+json"}
+
+	tests := []struct {
+		name        string
+		contentType string
+		supported   []string
+		expected    bool
+		expectErr   error
+	}{
+		{"supported", "application/merge-patch+json", supported, true, nil},
+		{"case insensitive", "Application/JSON-Patch+JSON", supported, true, nil},
+		{"extra parameter", "application/merge-patch+json; charset=utf-8", supported, true, nil},
+		{"unsupported", "application/json", supported, false, nil},
+		{"range", "application/merge-patch+json", []string{"application/*+json"}, true, nil},
+		{"required parameter", "text/plain", []string{"text/plain; charset=utf-8"}, false, nil},
+		{"full wildcard content type", "*/*", supported, false, nil},
+		{"type wildcard content type", "application/*", supported, false, nil},
+		{"invalid content type", "json", supported, false, ErrMalformedHeader},
+		{"no supported types", "application/merge-patch+json", nil, false, ErrEmptyPriorities},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := PatchAcceptable(tt.contentType, tt.supported)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, ok)
+		})
+	}
+
+	// An invalid supported type is the server's error, not a malformed client header.
+	_, err := PatchAcceptable("application/json", []string{"application/json", "json"})
+	var mediaErr *InvalidMediaTypeError
+	require.ErrorAs(t, err, &mediaErr)
+	assert.NotErrorIs(t, err, ErrMalformedHeader)
+}
+
+func TestApplyContentType(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "text/html;q=0.5, application/json")
+
+	w := httptest.NewRecorder()
+	w.Header().Set("Vary", "Origin")
+
+	chosen, err := ApplyContentType(w, r, []string{"text/html", "application/json; charset=utf-8"})
+	require.NoError(t, err)
+	assert.Equal(t, "application/json; charset=utf-8", chosen)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, []string{"Origin", "Accept"}, w.Header().Values("Vary"))
+
+	// Vary is not duplicated.
+	_, err = ApplyContentType(w, r, []string{"application/json"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Origin", "Accept"}, w.Header().Values("Vary"))
+}
+
+func TestApplyContentType_NoAcceptableMatch(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "image/png")
+
+	w := httptest.NewRecorder()
+
+	chosen, err := ApplyContentType(w, r, []string{"application/json"})
+	require.ErrorIs(t, err, ErrNoAcceptableMatch)
+	assert.Empty(t, chosen)
+	assert.Empty(t, w.Header().Get("Content-Type"))
+	assert.Equal(t, "Accept", w.Header().Get("Vary"), "the response varies on Accept even when negotiation fails")
+}
+
+func TestApplyContentLanguage(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	w := httptest.NewRecorder()
+	w.Header().Set("Vary", "Accept")
+
+	// An absent header accepts the first priority.
+	chosen, err := ApplyContentLanguage(w, r, []string{"en-US", "fr"})
+	require.NoError(t, err)
+	assert.Equal(t, "en-US", chosen)
+	assert.Equal(t, "en-US", w.Header().Get("Content-Language"))
+	assert.Equal(t, []string{"Accept", "Accept-Language"}, w.Header().Values("Vary"))
+}
+
+func TestApplyContentEncoding(t *testing.T) {
+	tests := []struct {
+		name      string
+		accept    []string
+		available []string
+		expected  string
+		header    string
+		expectErr error
+	}{
+		{"preferred coding", []string{"gzip;q=0.5, br"}, []string{"gzip", "br"}, "br", "br", nil},
+		{"multiple field lines", []string{"identity;q=0", "gzip"}, []string{"gzip"}, "gzip", "gzip", nil},
+		{"no header means identity", nil, []string{"gzip", "br"}, "identity", "", nil},
+		{"no available coding acceptable", []string{"zstd"}, []string{"gzip"}, "identity", "", nil},
+		{"identity chosen from available", []string{"identity, gzip;q=0.5"}, []string{"identity", "gzip"}, "identity", "", nil},
+		{"identity excluded", []string{"*;q=0"}, []string{"gzip"}, "", "", ErrNoAcceptableMatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			for _, accept := range tt.accept {
+				r.Header.Add("Accept-Encoding", accept)
+			}
+
+			w := httptest.NewRecorder()
+
+			coding, err := ApplyContentEncoding(w, r, tt.available)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			assert.Equal(t, tt.expected, coding)
+			assert.Equal(t, tt.header, w.Header().Get("Content-Encoding"))
+			_, set := w.Header()["Content-Encoding"]
+			assert.Equal(t, tt.header != "", set, "Content-Encoding is only set for a real coding")
+			assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+		})
+	}
+}
+
+func TestNegotiateWithOverride(t *testing.T) {
+	mapping := map[string]string{"json": "application/json", "html": "text/html", "xml": "application/xml"}
+	priorities := []string{"text/html", "application/json; charset=utf-8"}
+
+	tests := []struct {
+		name      string
+		target    string
+		accept    string
+		expected  string
+		expectErr error
+	}{
+		{"override wins over Accept", "/?format=json", "text/html", "application/json", nil},
+		{"override to first priority", "/?format=html", "application/json", "text/html", nil},
+		{"unknown value falls back to Accept", "/?format=yaml", "application/json", "application/json", nil},
+		{"no parameter falls back to Accept", "/", "application/json", "application/json", nil},
+		{"no parameter and no Accept", "/", "", "text/html", nil},
+		{"override to type not offered", "/?format=xml", "*/*", "", ErrNoAcceptableMatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+
+			result, err := NegotiateWithOverride(r, "format", mapping, priorities)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Type)
+		})
+	}
+}
+
+func TestNegotiateLanguageRedirect(t *testing.T) {
+	priorities := []string{"en", "de", "fr"}
+	urlFor := func(lang string) string { return "/" + lang + "/about" }
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptLanguage string
+		expectedURL    string
+		expectedOK     bool
+	}{
+		{"negotiated language", "/about", "de-DE, de;q=0.9, en;q=0.5", "/de/about", true},
+		{"default language", "/about", "en-US, de;q=0.5", "", false},
+		{"absent header", "/about", "", "", false},
+		{"already prefixed", "/de/about", "fr", "", false},
+		{"prefix case-insensitive", "/FR/about", "de", "", false},
+		{"prefix is whole segment", "/french/about", "fr", "/fr/about", true},
+		{"root path", "/", "fr", "/fr/about", true},
+		{"no acceptable language", "/about", "ja", "", false},
+		{"malformed quality ignored", "/about", "de;q=2", "/de/about", true},
+		{"malformed header", "/about", "@@@", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.acceptLanguage != "" {
+				r.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+
+			target, ok := NegotiateLanguageRedirect(r, priorities, urlFor)
+			assert.Equal(t, tt.expectedOK, ok)
+			assert.Equal(t, tt.expectedURL, target)
+		})
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/about", nil)
+	r.Header.Set("Accept-Language", "de")
+	_, ok := NegotiateLanguageRedirect(r, nil, urlFor)
+	assert.False(t, ok)
+}
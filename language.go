@@ -0,0 +1,40 @@
+package negotiation
+
+import (
+	"slices"
+	"strings"
+)
+
+// NegotiateLanguageSet negotiates the languages of a response declaring several
+// content languages, such as a multi-part response. It returns the best language
+// and all acceptable languages, best first, in the canonical BCP 47 casing of
+// the priorities for use in Content-Language. Languages are ranked as by
+// Negotiate; a regional priority is also acceptable through its base language,
+// see WithLanguageFallback, ranking below exact matches of equal quality.
+// Priorities naming the same language are listed once. As for NegotiateAll, an
+// absent header accepts anything and the header is parsed leniently.
+func NegotiateLanguageSet(accept string, priorities []string) (string, []string, error) {
+	if strings.TrimSpace(accept) == "" {
+		accept = "*"
+	}
+
+	negotiator := NewLanguageNegotiator(WithLanguageFallback(true))
+	n, err := negotiator.negotiate(accept, unweighted(priorities), false, false)
+	if err != nil {
+		return "", nil, err
+	}
+
+	ranked := negotiator.rankMatches(n.matches, n.priorities)
+	if len(ranked) == 0 {
+		return "", nil, ErrNoAcceptableMatch
+	}
+
+	all := make([]string, 0, len(ranked))
+	for _, match := range ranked {
+		if tag := n.priorities[match.Index].NormalizedValue; !slices.Contains(all, tag) {
+			all = append(all, tag)
+		}
+	}
+
+	return all[0], all, nil
+}
@@ -0,0 +1,90 @@
+package negotiation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateLanguageSet(t *testing.T) {
+	tests := []struct {
+		name         string
+		accept       string
+		priorities   []string
+		expectedBest string
+		expectedAll  []string
+		expectErr    error
+	}{
+		{
+			name:         "ordered by client quality",
+			accept:       "fr;q=0.5, en, de;q=0.8",
+			priorities:   []string{"de", "fr", "en", "es"},
+			expectedBest: "en",
+			expectedAll:  []string{"en", "de", "fr"},
+		},
+		{
+			name:         "canonical casing",
+			accept:       "zh-hans-cn, en",
+			priorities:   []string{"EN", "zh-hans-cn"},
+			expectedBest: "en",
+			expectedAll:  []string{"en", "zh-Hans-CN"},
+		},
+		{
+			name:         "base language fallback ranks below exact matches",
+			accept:       "en-GB, de;q=0.9",
+			priorities:   []string{"en-US", "de", "en-GB"},
+			expectedBest: "en-GB",
+			expectedAll:  []string{"en-GB", "en-US", "de"},
+		},
+		{
+			name:         "fallback and exact entries are not duplicated",
+			accept:       "en-GB, en",
+			priorities:   []string{"en-US", "en", "EN", "en-us"},
+			expectedBest: "en-US",
+			expectedAll:  []string{"en-US", "en"},
+		},
+		{
+			name:         "absent header accepts anything",
+			accept:       "",
+			priorities:   []string{"en", "fr"},
+			expectedBest: "en",
+			expectedAll:  []string{"en", "fr"},
+		},
+		{
+			name:         "rejected languages are left out",
+			accept:       "*, fr;q=0",
+			priorities:   []string{"fr", "en"},
+			expectedBest: "en",
+			expectedAll:  []string{"en"},
+		},
+		{
+			name:       "nothing acceptable",
+			accept:     "ja",
+			priorities: []string{"en", "fr"},
+			expectErr:  ErrNoAcceptableMatch,
+		},
+		{
+			name:      "no priorities",
+			accept:    "en",
+			expectErr: ErrEmptyPriorities,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			best, all, err := NegotiateLanguageSet(tt.accept, tt.priorities)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+				assert.Empty(t, best)
+				assert.Nil(t, all)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedBest, best)
+			assert.Equal(t, tt.expectedAll, all)
+		})
+	}
+}
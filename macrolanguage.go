@@ -0,0 +1,47 @@
+package negotiation
+
+import (
+	"maps"
+	"strings"
+)
+
+// macrolanguages maps individual languages to the ISO 639-3 macrolanguage
+// encompassing them. Subtags are lowercase, as language types are lowercased
+// by the parser.
+var macrolanguages = map[string]string{
+	"cmn": "zh",
+	"yue": "zh",
+	"wuu": "zh",
+	"hak": "zh",
+	"nan": "zh",
+	"gan": "zh",
+	"hsn": "zh",
+	"nb":  "no",
+	"nn":  "no",
+	"arb": "ar",
+	"pes": "fa",
+	"prs": "fa",
+	"zsm": "ms",
+	"swh": "sw",
+	"lvs": "lv",
+	"ekk": "et",
+	"uzn": "uz",
+	"khk": "mn",
+}
+
+// newMacrolanguages returns the built-in macrolanguage table extended with extra
+// mappings. Extra mappings take precedence and are lowercased to match parsed types.
+func newMacrolanguages(extra map[string]string) map[string]string {
+	table := maps.Clone(macrolanguages)
+	for language, macrolanguage := range extra {
+		table[strings.ToLower(language)] = strings.ToLower(macrolanguage)
+	}
+
+	return table
+}
+
+// relatedByMacrolanguage reports whether one of two primary language subtags is
+// the macrolanguage encompassing the other, e.g. "no" and "nb".
+func relatedByMacrolanguage(table map[string]string, a, b string) bool {
+	return table[a] == b || table[b] == a
+}
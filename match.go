@@ -0,0 +1,42 @@
+package negotiation
+
+// Match describes both sides of a successful negotiation.
+type Match struct {
+	// ClientElement is the most specific client element that matched the priority,
+	// e.g. "text/*;q=0.8" for the priority "text/html".
+	ClientElement *Header
+	// Priority is the winning priority exactly as given by the server.
+	Priority string
+	// Quality is the resolved quality of the priority.
+	Quality float64
+	// Specificity is the specificity score of the match; higher is more specific,
+	// e.g. type/subtype > type/* > */* for media types.
+	Specificity int
+	// MatchedParameters is the number of parameters of the client element that the
+	// priority matched, e.g. 1 for "text/html;level=1" against "text/html;level=1"
+	// and 0 for bare types. Among elements of equal specificity, more matched
+	// parameters are more specific.
+	MatchedParameters int
+}
+
+// NegotiateMatch behaves like Negotiate but returns both the client element and
+// the server priority of the match, so it is clear which side each value comes from.
+func (c *Negotiator) NegotiateMatch(header string, priorities []string, strict bool) (*Match, error) {
+	n, err := c.negotiate(header, unweighted(priorities), strict, false)
+	if err != nil {
+		return nil, err
+	}
+
+	c.observe(n)
+	if n.best == nil {
+		return nil, ErrNoAcceptableMatch
+	}
+
+	return &Match{
+		ClientElement:     n.best.Accept,
+		Priority:          givenPriority(n.priorities[n.best.Index], priorities),
+		Quality:           n.best.Quality,
+		Specificity:       n.best.Score,
+		MatchedParameters: n.best.Params,
+	}, nil
+}
@@ -0,0 +1,124 @@
+package negotiation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiator_NegotiateMatch(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	tests := []struct {
+		name                string
+		header              string
+		priorities          []string
+		expectedPriority    string
+		expectedElement     string
+		expectedQuality     float64
+		expectedSpecificity int
+	}{
+		{
+			name:                "exact match keeps priority casing",
+			header:              "TEXT/HTML;q=0.9, application/json;q=0.5",
+			priorities:          []string{"application/json", "Text/HTML"},
+			expectedPriority:    "Text/HTML",
+			expectedElement:     "TEXT/HTML;q=0.9",
+			expectedQuality:     0.9,
+			expectedSpecificity: 110,
+		},
+		{
+			name:                "type wildcard",
+			header:              "text/*;q=0.8",
+			priorities:          []string{"text/html"},
+			expectedPriority:    "text/html",
+			expectedElement:     "text/*;q=0.8",
+			expectedQuality:     0.8,
+			expectedSpecificity: 100,
+		},
+		{
+			name:                "full wildcard",
+			header:              "*/*",
+			priorities:          []string{"application/json; charset=utf-8"},
+			expectedPriority:    "application/json; charset=utf-8",
+			expectedElement:     "*/*",
+			expectedQuality:     1,
+			expectedSpecificity: 0,
+		},
+		{
+			name:                "wildcard priority is reported as given",
+			header:              "text/csv;q=0.5",
+			priorities:          []string{"application/json", "*/*"},
+			expectedPriority:    "*/*",
+			expectedElement:     "text/csv;q=0.5",
+			expectedQuality:     0.5,
+			expectedSpecificity: 110,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := negotiator.NegotiateMatch(tt.header, tt.priorities, true)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedPriority, match.Priority)
+			require.NotNil(t, match.ClientElement)
+			assert.Equal(t, tt.expectedElement, match.ClientElement.Value)
+			assert.InDelta(t, tt.expectedQuality, match.Quality, 0.0001)
+			assert.Equal(t, tt.expectedSpecificity, match.Specificity)
+		})
+	}
+}
+
+func TestNegotiator_NegotiateMatch_MatchedParameters(t *testing.T) {
+	tests := []struct {
+		name       string
+		negotiator *Negotiator
+		header     string
+		priorities []string
+		expected   int
+	}{
+		{"one parameter", NewMediaNegotiator(), "text/html;level=1", []string{"text/html;level=1"}, 1},
+		{"bare match", NewMediaNegotiator(), "text/html", []string{"text/html"}, 0},
+		{"priority parameters only", NewMediaNegotiator(), "text/html", []string{"text/html;level=1"}, 0},
+		{"client parameter ignored", NewMediaNegotiator(), "text/html;level=1", []string{"text/html"}, 0},
+		{"two parameters", NewMediaNegotiator(), "text/html;level=1;charset=utf-8", []string{"text/html;charset=UTF-8;level=1"}, 2},
+		{"most specific range", NewMediaNegotiator(), "text/html;q=0.5, text/html;level=1", []string{"text/html;level=1"}, 1},
+		{"wildcard with parameter", NewMediaNegotiator(), "text/*;charset=utf-8", []string{"text/plain;charset=utf-8"}, 1},
+		{"language", NewLanguageNegotiator(), "en", []string{"en"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := tt.negotiator.NegotiateMatch(tt.header, tt.priorities, true)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, match.MatchedParameters)
+		})
+	}
+}
+
+func TestNegotiator_NegotiateMatch_Errors(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	match, err := negotiator.NegotiateMatch("image/png", []string{"text/html"}, true)
+	require.ErrorIs(t, err, ErrNoAcceptableMatch)
+	assert.Nil(t, match)
+
+	match, err = negotiator.NegotiateMatch("text/html", nil, true)
+	require.ErrorIs(t, err, ErrEmptyPriorities)
+	assert.Nil(t, match)
+}
+
+func TestNegotiator_NegotiateMatch_AgreesWithNegotiate(t *testing.T) {
+	negotiator := NewLanguageNegotiator(WithLanguageFallback(true))
+	header := "fr-CA, en;q=0.8"
+	priorities := []string{"en-GB", "fr"}
+
+	best, err := negotiator.Negotiate(header, priorities, false)
+	require.NoError(t, err)
+
+	match, err := negotiator.NegotiateMatch(header, priorities, false)
+	require.NoError(t, err)
+	assert.Equal(t, best.Value, match.Priority)
+	assert.Equal(t, "fr-ca", match.ClientElement.Type)
+}
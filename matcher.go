@@ -9,14 +9,25 @@ type matchResult struct {
 	Quality float64
 	Score   int
 	Index   int
+	// Accept is the client element that produced the match.
+	Accept *Header
+	// Via tells how the client element matched the priority.
+	Via MatchKind
+	// Params counts the parameters of the client element that the priority matched;
+	// among ranges of equal score, more matched parameters are more specific
+	// (e.g. text/html;level=1 over text/html).
+	Params int
+	// Fallback marks a degraded match (e.g. language base-only fallback),
+	// which loses ties against regular matches of equal quality.
+	Fallback bool
 }
 
 // matcher determines if an accept header matches a priority.
-type matcher func(accept, priority *Header, index int) *matchResult
+type matcher func(accept, priority *Header, index int, opts *options) *matchResult
 
 // matchMediaType matches media types with support for wildcards and plus-segments.
-func matchMediaType(accept, priority *Header, index int) *matchResult {
-	if !paramsMatch(accept.Parameters, priority.Parameters) {
+func matchMediaType(accept, priority *Header, index int, opts *options) *matchResult {
+	if !paramsMatch(accept.Parameters, priority.Parameters, opts) {
 		return nil
 	}
 
@@ -27,8 +38,12 @@ func matchMediaType(accept, priority *Header, index int) *matchResult {
 		return nil
 	}
 
+	tree := false
 	if !matchesSubtype(acceptSubPart, prioritySubPart) {
-		return nil
+		if !opts.treeMatching || !matchesTree(acceptSubPart, prioritySubPart) {
+			return nil
+		}
+		tree = true
 	}
 
 	if !matchesSuffix(acceptSuffix, prioritySuffix) {
@@ -40,11 +55,34 @@ func matchMediaType(accept, priority *Header, index int) *matchResult {
 		acceptSubPart, prioritySubPart,
 		acceptSuffix, prioritySuffix,
 	)
+	via := mediaMatchKind(accept.BasePart, acceptSubPart, acceptSuffix, prioritySubPart)
+	if tree {
+		score += treeScore
+		via = MatchTree
+	}
 
 	return &matchResult{
 		Quality: accept.Quality * priority.Quality,
 		Score:   score,
 		Index:   index,
+		Via:     via,
+		Params:  countParams(priority.Parameters, accept.Parameters),
+		// A tree match or a range with parameters the priority does not specify only partly applies
+		Fallback: tree || !hasParams(priority.Parameters, accept.Parameters),
+	}
+}
+
+// mediaMatchKind classifies a media type match by the most general wildcard involved.
+func mediaMatchKind(acceptBase, acceptSubPart, acceptSuffix, prioritySubPart string) MatchKind {
+	switch {
+	case acceptBase == "*":
+		return MatchFullWildcard
+	case acceptSubPart == "*" && acceptSuffix != "" && acceptSuffix != "*":
+		return MatchSuffix
+	case acceptSubPart == "*" || prioritySubPart == "*":
+		return MatchTypeWildcard
+	default:
+		return MatchExact
 	}
 }
 
@@ -59,6 +97,36 @@ func matchesSubtype(acceptSubPart, prioritySubPart string) bool {
 		strings.EqualFold(acceptSubPart, prioritySubPart)
 }
 
+// treeScore is the score of a tree match, between a type wildcard and an exact subtype.
+const treeScore = 5
+
+// matchesTree checks if the priority subtype is the same or a more general facet
+// of the accept subtype in a vendor, personal or unregistered tree (RFC 6838),
+// e.g. "vnd.company" or "vnd" for "vnd.company.invoice". Suffixes are already split off.
+func matchesTree(acceptSubPart, prioritySubPart string) bool {
+	acceptTree, acceptName := splitTree(acceptSubPart)
+	priorityTree, priorityName := splitTree(prioritySubPart)
+	if acceptTree == "" || acceptTree != priorityTree {
+		return false
+	}
+
+	return priorityName == "" || priorityName == acceptName ||
+		strings.HasPrefix(acceptName, priorityName+".")
+}
+
+// splitTree splits a subtype into its registration tree and the facet name within
+// the tree, e.g. ("vnd", "company.invoice") for "vnd.company.invoice". The tree is
+// empty for subtypes of the standards tree.
+func splitTree(subPart string) (string, string) {
+	tree, name, _ := strings.Cut(subPart, ".")
+	switch tree {
+	case "vnd", "prs", "x":
+		return tree, name
+	default:
+		return "", subPart
+	}
+}
+
 // matchesSuffix checks if suffix parts match (RFC 6839).
 func matchesSuffix(acceptSuffix, prioritySuffix string) bool {
 	return (acceptSuffix == "" && prioritySuffix == "") ||
@@ -67,6 +135,9 @@ func matchesSuffix(acceptSuffix, prioritySuffix string) bool {
 }
 
 // calculateMediaTypeScore calculates the match score for media types.
+// The score implements the RFC 7231 specificity ladder: an exact base part
+// scores 100, an exact subtype adds 10 and an exact suffix adds 1, so
+// type/subtype > type/* > */*.
 func calculateMediaTypeScore(acceptBase, priorityBase, acceptSubPart, prioritySubPart, acceptSuffix, prioritySuffix string) int {
 	baseEqual := strings.EqualFold(acceptBase, priorityBase)
 	score := 100 * boolToInt(baseEqual)
@@ -97,24 +168,66 @@ func splitSubPart(subPart string) (string, string) {
 	return subPart, ""
 }
 
+// macrolanguageScore is the base specificity of a macrolanguage match, below
+// the 100 of a primary subtag match and above the "*" range.
+const macrolanguageScore = 50
+
 // MatchLanguage matches languages with support for base/sub matching and fallback.
-func matchLanguage(accept, priority *Header, index int) *matchResult {
+func matchLanguage(accept, priority *Header, index int, opts *options) *matchResult {
 	ab := accept.BasePart
 	pb := priority.BasePart
 	as := accept.SubPart
 	ps := priority.SubPart
 
+	asc := accept.ScriptPart
+	psc := priority.ScriptPart
+
 	baseEqual := strings.EqualFold(ab, pb)
+	scriptEqual := strings.EqualFold(asc, psc)
 	subEqual := strings.EqualFold(as, ps)
 
-	// Match if base parts match (or accept is wildcard) and sub parts match or are nil
-	if (ab == "*" || baseEqual) && (as == "" || subEqual || ps == "") {
-		score := 10*boolToInt(baseEqual) + boolToInt(subEqual)
+	// The "*" range (RFC 4647) matches any language with the lowest specificity
+	if ab == "*" {
+		return &matchResult{
+			Quality: accept.Quality * priority.Quality,
+			Score:   0,
+			Index:   index,
+			Via:     MatchFullWildcard,
+		}
+	}
+
+	// Match if base parts match and script and sub parts match or are nil
+	if baseEqual && (asc == "" || scriptEqual || psc == "") && (as == "" || subEqual || ps == "") {
+		score := 100*boolToInt(baseEqual) + 10*boolToInt(scriptEqual) + boolToInt(subEqual)
 
 		return &matchResult{
 			Quality: accept.Quality * priority.Quality,
 			Score:   score,
 			Index:   index,
+			Via:     MatchExact,
+		}
+	}
+
+	// Fall back to the base subtag (en-GB -> en-US)
+	if opts.languageFallback && baseEqual {
+		return &matchResult{
+			Quality:  accept.Quality * priority.Quality,
+			Score:    100,
+			Index:    index,
+			Via:      MatchLanguageFallback,
+			Fallback: true,
+		}
+	}
+
+	// Relate a language to its macrolanguage (nb -> no) below any base match
+	if opts.macrolanguages != nil && relatedByMacrolanguage(opts.macrolanguages, ab, pb) &&
+		(asc == "" || scriptEqual || psc == "") && (as == "" || subEqual || ps == "") {
+		return &matchResult{
+			Quality:  accept.Quality * priority.Quality,
+			Score:    macrolanguageScore + 10*boolToInt(scriptEqual) + boolToInt(subEqual),
+			Index:    index,
+			Via:      MatchMacrolanguage,
+			Fallback: true,
 		}
 	}
 
@@ -122,7 +235,7 @@ func matchLanguage(accept, priority *Header, index int) *matchResult {
 }
 
 // MatchSimple matches simple string types (charset, encoding) with wildcard support.
-func matchSimple(accept, priority *Header, index int) *matchResult {
+func matchSimple(accept, priority *Header, index int, _ *options) *matchResult {
 	ac := accept.Type
 	pc := priority.Type
 
@@ -135,18 +248,68 @@ func matchSimple(accept, priority *Header, index int) *matchResult {
 			Quality: accept.Quality * priority.Quality,
 			Score:   score,
 			Index:   index,
+			Via:     wildcardMatchKind(ac),
 		}
 	}
 
 	return nil
 }
 
-// paramsMatch checks that all accept parameters are satisfied by priority parameters.
-// Per RFC 7231: priority (server) must satisfy all accept (client) parameter requirements.
-func paramsMatch(acceptParams, priorityParams map[string]string) bool {
+// wildcardMatchKind classifies a match of a single-part type such as a language or charset.
+func wildcardMatchKind(acceptType string) MatchKind {
+	if acceptType == "*" {
+		return MatchFullWildcard
+	}
+
+	return MatchExact
+}
+
+// paramsMatch checks that the accept parameters are satisfied by priority parameters.
+// A parameter specified by both must have equal values; an accept parameter the
+// priority does not specify is ignored unless exact parameter matching is enabled.
+// Parameters only the priority specifies never prevent a match, and ignored
+// parameters such as a multipart boundary never affect it. Parameter names are
+// already lowercased by the parser; values are compared case-insensitively unless
+// case-sensitive values are enabled. Charset values are compared across aliases
+// when charset parameter matching is enabled.
+func paramsMatch(acceptParams, priorityParams map[string]string, opts *options) bool {
 	for k, acceptValue := range acceptParams {
+		if ignoredParams[k] {
+			continue
+		}
+
 		priorityValue, ok := priorityParams[k]
-		if !ok || !strings.EqualFold(acceptValue, priorityValue) {
+		if !ok {
+			if opts.exactParameterMatch {
+				return false
+			}
+
+			continue
+		}
+
+		if k == "charset" && opts.charsetParamAliases != nil {
+			acceptValue = resolveAlias(opts.charsetParamAliases, acceptValue)
+			priorityValue = resolveAlias(opts.charsetParamAliases, priorityValue)
+		}
+
+		if !paramValuesEqual(acceptValue, priorityValue, opts.caseSensitiveParamValues) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ignoredParams are parameters that describe the representation rather than
+// what is acceptable, so they are ignored in matching: a multipart boundary
+// is chosen per message and says nothing about acceptability.
+var ignoredParams = map[string]bool{"boundary": true}
+
+// hasParams reports whether params specifies every parameter name of required,
+// disregarding ignored parameters.
+func hasParams(params, required map[string]string) bool {
+	for k := range required {
+		if _, ok := params[k]; !ok && !ignoredParams[k] {
 			return false
 		}
 	}
@@ -154,6 +317,28 @@ func paramsMatch(acceptParams, priorityParams map[string]string) bool {
 	return true
 }
 
+// countParams returns how many parameter names of required params specifies,
+// disregarding ignored parameters.
+func countParams(params, required map[string]string) int {
+	count := 0
+	for k := range required {
+		if _, ok := params[k]; ok && !ignoredParams[k] {
+			count++
+		}
+	}
+
+	return count
+}
+
+// paramValuesEqual compares two parameter values.
+func paramValuesEqual(a, b string, caseSensitive bool) bool {
+	if caseSensitive {
+		return a == b
+	}
+
+	return strings.EqualFold(a, b)
+}
+
 // boolToInt converts a boolean to an integer (1 for true, 0 for false).
 func boolToInt(b bool) int {
 	if b {
@@ -59,3 +59,65 @@ func TestMatch_SortOrder(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchesTree(t *testing.T) {
+	tests := []struct {
+		name     string
+		accept   string
+		priority string
+		expected bool
+	}{
+		{"same facet", "vnd.company", "vnd.company", true},
+		{"more general facet", "vnd.company.invoice", "vnd.company", true},
+		{"whole tree", "vnd.company.invoice", "vnd", true},
+		{"personal tree", "prs.alice.notes", "prs.alice", true},
+		{"unregistered tree", "x.example", "x", true},
+		{"more specific priority", "vnd.company", "vnd.company.invoice", false},
+		{"facet name prefix is not a facet", "vnd.companyx", "vnd.company", false},
+		{"vendor does not match personal", "vnd.company", "prs.company", false},
+		{"personal does not match vendor", "prs.company", "vnd", false},
+		{"standards tree", "json", "json", false},
+		{"x- prefix is not a tree", "x-foo", "x", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, matchesTree(tt.accept, tt.priority))
+		})
+	}
+}
+
+func TestParamsMatch(t *testing.T) {
+	tests := []struct {
+		name          string
+		accept        map[string]string
+		priority      map[string]string
+		caseSensitive bool
+		exact         bool
+		expected      bool
+	}{
+		{"no accept params", nil, map[string]string{"charset": "utf-8"}, false, false, true},
+		{"equal values", map[string]string{"level": "1"}, map[string]string{"level": "1"}, false, false, true},
+		{"different values", map[string]string{"level": "1"}, map[string]string{"level": "2"}, false, false, false},
+		{"missing in priority", map[string]string{"level": "1"}, nil, false, false, true},
+		{"missing in priority exact", map[string]string{"level": "1"}, nil, false, true, false},
+		{"case-insensitive values", map[string]string{"charset": "UTF-8"}, map[string]string{"charset": "utf-8"}, false, false, true},
+		{"case-sensitive values", map[string]string{"charset": "UTF-8"}, map[string]string{"charset": "utf-8"}, true, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &options{caseSensitiveParamValues: tt.caseSensitive, exactParameterMatch: tt.exact}
+			assert.Equal(t, tt.expected, paramsMatch(tt.accept, tt.priority, opts))
+		})
+	}
+}
+
+func TestParamsMatch_CharsetAliases(t *testing.T) {
+	opts := &options{caseSensitiveParamValues: true, charsetParamAliases: newCharsetAliases(nil)}
+
+	assert.True(t, paramsMatch(map[string]string{"charset": "utf8"}, map[string]string{"charset": "UTF-8"}, opts))
+	assert.True(t, paramsMatch(map[string]string{"charset": "Latin1"}, map[string]string{"charset": "iso-8859-1"}, opts))
+	assert.False(t, paramsMatch(map[string]string{"charset": "utf8"}, map[string]string{"charset": "iso-8859-1"}, opts))
+	assert.False(t, paramsMatch(map[string]string{"level": "A"}, map[string]string{"level": "a"}, opts), "other parameters keep case-sensitivity")
+}
@@ -0,0 +1,77 @@
+// Package negotiationtest provides helpers for testing content negotiation wiring.
+package negotiationtest
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/talav/negotiation"
+)
+
+// AssertNegotiates checks that negotiating header against priorities in strict mode
+// selects expected, a priority as given. On failure it reports the client elements
+// in order of preference to explain the outcome. It returns whether the check passed.
+func AssertNegotiates(t testing.TB, negotiator *negotiation.Negotiator, header string, priorities []string, expected string) bool {
+	t.Helper()
+
+	best, err := negotiator.Negotiate(header, priorities, true)
+	if err != nil {
+		t.Errorf("negotiating %q against %q: expected %q, got error: %v\n%s",
+			header, priorities, expected, err, describeElements(negotiator, header))
+
+		return false
+	}
+
+	if best.Value != expected {
+		t.Errorf("negotiating %q against %q: expected %q, got %q\n%s",
+			header, priorities, expected, best.Value, describeElements(negotiator, header))
+
+		return false
+	}
+
+	return true
+}
+
+// AssertNotAcceptable checks that none of the priorities is acceptable for header,
+// i.e. that Negotiate fails with negotiation.ErrNoAcceptableMatch.
+// It returns whether the check passed.
+func AssertNotAcceptable(t testing.TB, negotiator *negotiation.Negotiator, header string, priorities []string) bool {
+	t.Helper()
+
+	best, err := negotiator.Negotiate(header, priorities, true)
+	if errors.Is(err, negotiation.ErrNoAcceptableMatch) {
+		return true
+	}
+
+	if err != nil {
+		t.Errorf("negotiating %q against %q: expected no acceptable match, got error: %v\n%s",
+			header, priorities, err, describeElements(negotiator, header))
+	} else {
+		t.Errorf("negotiating %q against %q: expected no acceptable match, got %q\n%s",
+			header, priorities, best.Value, describeElements(negotiator, header))
+	}
+
+	return false
+}
+
+// describeElements lists the elements of header in order of preference.
+func describeElements(negotiator *negotiation.Negotiator, header string) string {
+	elements, err := negotiator.GetOrderedElements(header)
+	if err != nil {
+		return "header elements: " + err.Error()
+	}
+
+	var b strings.Builder
+	b.WriteString("header elements in order of preference:")
+	for _, e := range elements {
+		b.WriteString("\n  ")
+		b.WriteString(e.NormalizedValue)
+		b.WriteString(" (q=")
+		b.WriteString(strconv.FormatFloat(e.Quality, 'f', -1, 64))
+		b.WriteString(")")
+	}
+
+	return b.String()
+}
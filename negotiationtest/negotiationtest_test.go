@@ -0,0 +1,58 @@
+package negotiationtest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/talav/negotiation"
+)
+
+// recordingTB captures failures instead of failing the test.
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertNegotiates(t *testing.T) {
+	negotiator := negotiation.NewMediaNegotiator()
+
+	assert.True(t, AssertNegotiates(t, negotiator, "text/html;q=0.5, application/json", []string{"text/html", "application/json"}, "application/json"))
+
+	rec := &recordingTB{}
+	assert.False(t, AssertNegotiates(rec, negotiator, "text/html;q=0.5, application/json", []string{"text/html", "application/json"}, "text/html"))
+	assert.Len(t, rec.errors, 1)
+	assert.Equal(t, `negotiating "text/html;q=0.5, application/json" against ["text/html" "application/json"]: expected "text/html", got "application/json"
+header elements in order of preference:
+  application/json (q=1)
+  text/html (q=0.5)`, rec.errors[0])
+
+	rec = &recordingTB{}
+	assert.False(t, AssertNegotiates(rec, negotiator, "image/png", []string{"text/html"}, "text/html"))
+	assert.Len(t, rec.errors, 1)
+	assert.Contains(t, rec.errors[0], "no matching header found")
+	assert.Contains(t, rec.errors[0], "image/png (q=1)")
+}
+
+func TestAssertNotAcceptable(t *testing.T) {
+	negotiator := negotiation.NewLanguageNegotiator()
+
+	assert.True(t, AssertNotAcceptable(t, negotiator, "fr, *;q=0", []string{"en"}))
+
+	rec := &recordingTB{}
+	assert.False(t, AssertNotAcceptable(rec, negotiator, "fr, en;q=0.1", []string{"en"}))
+	assert.Len(t, rec.errors, 1)
+	assert.Contains(t, rec.errors[0], `expected no acceptable match, got "en"`)
+
+	rec = &recordingTB{}
+	assert.False(t, AssertNotAcceptable(rec, negotiator, "", []string{"en"}))
+	assert.Len(t, rec.errors, 1)
+	assert.Contains(t, rec.errors[0], "header elements: the header string should not be empty")
+}
@@ -1,9 +1,14 @@
 package negotiation
 
 import (
+	"cmp"
+	"errors"
+	"fmt"
 	"maps"
 	"slices"
 	"sort"
+	"strconv"
+	"strings"
 )
 
 // headerFactory creates Header instances from string values.
@@ -11,128 +16,978 @@ type headerFactory func(value string) (*Header, error)
 
 // Negotiator handles all negotiation logic.
 type Negotiator struct {
-	factory headerFactory
-	matcher matcher
+	factory    headerFactory
+	matcher    matcher
+	opts       options
+	comparator func(a, b *Header) int
+	// aliases maps type aliases to canonical types, applied before the type normalizer.
+	aliases map[string]string
+	// headerName is the request header negotiated, reported to the observer.
+	headerName string
+	// cache memoizes negotiation results; nil disables caching, see WithResultCache.
+	cache *resultCache
+	// maxParameters is the most parameters a header element may have; 0 means no limit.
+	maxParameters int
 }
 
+// DefaultMaxParameters is the default limit on the number of parameters of a
+// header element, see SetMaxParameters.
+const DefaultMaxParameters = 32
+
 // NewCharsetNegotiator creates a new Negotiator for charsets.
-func NewCharsetNegotiator() *Negotiator {
-	return newNegotiator(newCharset, matchSimple)
+// Common charset aliases such as "utf8" or "latin1" are resolved to their
+// preferred IANA name before matching, see WithCharsetAliases.
+func NewCharsetNegotiator(opts ...Option) *Negotiator {
+	n := newNegotiator("Accept-Charset", newCharset, matchSimple, opts...)
+	n.aliases = newCharsetAliases(n.opts.charsetAliases)
+
+	return n
 }
 
 // NewEncodingNegotiator creates a new Negotiator for encodings.
-func NewEncodingNegotiator() *Negotiator {
-	return newNegotiator(newEncoding, matchSimple)
+// An empty header means that only the identity encoding is acceptable
+// (RFC 7231, section 5.3.4), see WithEmptyHeader.
+func NewEncodingNegotiator(opts ...Option) *Negotiator {
+	return newNegotiator("Accept-Encoding", newEncoding, matchSimple, append([]Option{WithEmptyHeader("identity")}, opts...)...)
+}
+
+// NewTENegotiator creates a new Negotiator for TE transfer codings.
+// It shares the encoding machinery and treats "trailers" as a valid coding
+// without q weighting, so Negotiate(te, []string{"trailers"}, false)
+// reports whether the client accepts trailer fields.
+func NewTENegotiator(opts ...Option) *Negotiator {
+	return newNegotiator("TE", newTransferCoding, matchSimple, opts...)
 }
 
 // NewLanguageNegotiator creates a new Negotiator for languages.
-func NewLanguageNegotiator() *Negotiator {
-	return newNegotiator(newLanguage, matchLanguage)
+func NewLanguageNegotiator(opts ...Option) *Negotiator {
+	n := newNegotiator("Accept-Language", newLanguage, matchLanguage, opts...)
+	if n.opts.macrolanguageMatching {
+		n.opts.macrolanguages = newMacrolanguages(n.opts.macrolanguageExtras)
+	}
+
+	return n
 }
 
 // NewMediaNegotiator creates a new Negotiator for media types.
-func NewMediaNegotiator() *Negotiator {
-	return newNegotiator(newMedia, matchMediaType)
+func NewMediaNegotiator(opts ...Option) *Negotiator {
+	n := newNegotiator("Accept", newMedia, matchMediaType, opts...)
+	if n.opts.charsetParamMatching {
+		n.opts.charsetParamAliases = newCharsetAliases(n.opts.charsetAliases)
+	}
+
+	return n
+}
+
+// NewGenericNegotiator creates a new Negotiator for a custom Accept-like header,
+// such as a proprietary "Accept-Foo", that uses the shared q-value grammar.
+// parseType validates and normalizes the lowercase type of each element and
+// priority, without parameters and quality; its errors are returned as parse
+// errors. A nil parseType accepts any type. Types match exactly or through the
+// "*" wildcard and are ranked like any other header. headerName is reported
+// to the observer, see WithObserver.
+func NewGenericNegotiator(headerName string, parseType func(typ string) (string, error), opts ...Option) *Negotiator {
+	return newNegotiator(headerName, newGeneric(parseType), matchSimple, opts...)
 }
 
-// newNegotiator creates a new Negotiator with the given factory and matcher.
-func newNegotiator(factory headerFactory, matcher matcher) *Negotiator {
-	return &Negotiator{
-		factory: factory,
-		matcher: matcher,
+// newNegotiator creates a new Negotiator for the named header with the given
+// factory, matcher and options.
+func newNegotiator(headerName string, factory headerFactory, matcher matcher, opts ...Option) *Negotiator {
+	n := &Negotiator{
+		factory:       factory,
+		matcher:       matcher,
+		headerName:    headerName,
+		maxParameters: DefaultMaxParameters,
+	}
+	for _, opt := range opts {
+		opt(&n.opts)
+	}
+	if n.opts.resultCacheSize > 0 {
+		n.cache = newResultCache(n.opts.resultCacheSize)
 	}
+
+	return n
+}
+
+// SetComparator overrides the ordering used by GetOrderedElements and Negotiate.
+// The comparator returns a negative number when a should come before b, as for
+// slices.SortFunc; elements it reports as equal keep their original order.
+// In Negotiate it compares the acceptable priorities, each carrying its resolved
+// quality. Passing nil restores DefaultComparator. SetComparator must not be
+// called concurrently with negotiation. It clears the result cache, if any.
+func (c *Negotiator) SetComparator(compare func(a, b *Header) int) {
+	c.comparator = compare
+	c.cache.clear()
 }
 
-// GetBest returns the best matching accept header from priorities based on the header.
+// SetMaxParameters limits the number of parameters of a header element to n,
+// DefaultMaxParameters by default, to bound the work a single element can cause.
+// Media range parameters and accept-ext parameters count alike; q does not. An
+// element over the limit is a TooManyParametersError: it fails the header in
+// strict mode and is skipped otherwise. A limit of 0 or less removes it.
+// Priorities are not limited. SetMaxParameters must not be called concurrently
+// with negotiation. It clears the result cache, if any.
+func (c *Negotiator) SetMaxParameters(n int) {
+	c.maxParameters = max(n, 0)
+	c.cache.clear()
+}
+
+// DefaultComparator orders headers by quality descending, then by original index.
+func DefaultComparator(a, b *Header) int {
+	if c := cmp.Compare(b.Quality, a.Quality); c != 0 {
+		return c
+	}
+
+	return cmp.Compare(a.originalIndex, b.originalIndex)
+}
+
+// Negotiate returns the best matching priority based on the header.
 // If strict is true, returns errors for invalid headers; otherwise skips invalid entries.
+// Priorities are server capabilities, not preferences: any q parameter in a
+// priority string is ignored. Use NegotiateServerQuality to honor it.
 func (c *Negotiator) Negotiate(header string, priorities []string, strict bool) (*Header, error) {
+	return c.NegotiateWeighted(header, unweighted(priorities), strict)
+}
+
+// NegotiateWeighted returns the best matching priority like Negotiate, using the
+// server weight and cost of each priority to break ties between priorities the
+// client accepts with equal quality. The precedence is: client quality, then
+// higher weight, then lower cost, then priority order. Weights and costs never
+// override client quality.
+func (c *Negotiator) NegotiateWeighted(header string, priorities []WeightedPriority, strict bool) (*Header, error) {
+	return c.negotiateBest(header, priorities, strict, false)
+}
+
+// NegotiateRanked returns the best matching priority like Negotiate, breaking
+// ties between priorities the client accepts with equal quality by their rank
+// instead of their slice order: the lower rank wins. Priorities of equal rank
+// fall back to slice order. Use it when the priority slice is built in an
+// unreliable order, e.g. from a map.
+func (c *Negotiator) NegotiateRanked(header string, priorities []RankedPriority, strict bool) (*Header, error) {
+	return c.NegotiateWeighted(header, byRank(priorities), strict)
+}
+
+// NegotiateMap returns the best matching priority like NegotiateWeighted, with
+// priorities given as a map from priority string to server weight, so each
+// priority appears once. Ties between priorities the client accepts with equal
+// quality go to the higher weight; priorities of equal weight are ordered by
+// their string, never by map iteration order.
+func (c *Negotiator) NegotiateMap(header string, priorities map[string]float64, strict bool) (*Header, error) {
+	return c.NegotiateWeighted(header, fromMap(priorities), strict)
+}
+
+// NegotiateServerQuality returns the best matching priority like Negotiate, but
+// interprets a q parameter on a priority as server preference: the resolved
+// quality of a priority is the client quality multiplied by its server quality
+// (1 if omitted), so "application/json;q=1, text/html;q=0.5" prefers JSON unless
+// the client prefers HTML at least twice as much. Unlike Negotiate, which ignores
+// q on priorities, a priority with q=0 is never chosen.
+func (c *Negotiator) NegotiateServerQuality(header string, priorities []string, strict bool) (*Header, error) {
+	return c.negotiateBest(header, unweighted(priorities), strict, true)
+}
+
+// NegotiateChain negotiates against each header of accepts in order, e.g. the
+// client's Accept header and then one supplied by a gateway, and returns the
+// best matching priority for the first header that yields a match. A header that
+// fails to parse or is empty also falls through to the next one. If no header
+// yields a match, ErrNoAcceptableMatch is returned, or all errors joined if any
+// header failed for another reason. The observer is notified once, of the outcome.
+func (c *Negotiator) NegotiateChain(accepts []string, priorities []string, strict bool) (*Header, error) {
 	if len(priorities) == 0 {
-		return nil, &InvalidArgumentError{Message: "a set of server priorities should be given"}
+		return nil, ErrEmptyPriorities
+	}
+	if len(accepts) == 0 {
+		return nil, ErrEmptyHeader
+	}
+
+	var errs []error
+	noMatchOnly := true
+	for _, header := range accepts {
+		n, err := c.negotiate(header, unweighted(priorities), strict, false)
+		if err == nil && n.best != nil {
+			c.observe(n)
+
+			return n.bestHeader(), nil
+		}
+
+		if err == nil {
+			err = ErrNoAcceptableMatch
+		}
+		noMatchOnly = noMatchOnly && errors.Is(err, ErrNoAcceptableMatch)
+		errs = append(errs, err)
+	}
+
+	if c.opts.observer != nil {
+		c.opts.observer.OnNoMatch(c.headerName)
+	}
+	if noMatchOnly {
+		return nil, ErrNoAcceptableMatch
+	}
+
+	return nil, errors.Join(errs...)
+}
+
+// negotiateBest negotiates and returns the winning priority, reporting the outcome
+// to the observer. Results are memoized if the result cache is enabled.
+func (c *Negotiator) negotiateBest(header string, priorities []WeightedPriority, strict, serverQuality bool) (*Header, error) {
+	var key resultKey
+	if c.cache != nil {
+		key = c.cache.key(header, priorities, strict, serverQuality)
+		if r, ok := c.cache.get(key, priorities); ok {
+			return c.fromCache(r)
+		}
+	}
+
+	n, err := c.negotiate(header, priorities, strict, serverQuality)
+	if err != nil {
+		c.cache.put(key, &cachedResult{priorities: slices.Clone(priorities), err: err})
+
+		return nil, err
 	}
 
-	if header == "" {
-		return nil, &InvalidArgumentError{Message: "the header string should not be empty"}
+	c.observe(n)
+	if n.best == nil {
+		c.cache.put(key, &cachedResult{priorities: slices.Clone(priorities), err: ErrNoAcceptableMatch})
+
+		return nil, ErrNoAcceptableMatch
+	}
+
+	best := n.bestHeader()
+	c.cache.put(key, &cachedResult{priorities: slices.Clone(priorities), best: best.clone(), quality: n.best.Quality})
+
+	return best, nil
+}
+
+// fromCache returns a memoized negotiation result, reporting the outcome to
+// the observer as the negotiation did. The winning priority is cloned so callers
+// cannot modify the cached one.
+func (c *Negotiator) fromCache(r *cachedResult) (*Header, error) {
+	if r.err != nil {
+		if c.opts.observer != nil && errors.Is(r.err, ErrNoAcceptableMatch) {
+			c.opts.observer.OnNoMatch(c.headerName)
+		}
+
+		return nil, r.err
+	}
+
+	if c.opts.observer != nil {
+		c.opts.observer.OnMatch(c.headerName, r.best.Value, r.quality)
+	}
+
+	return r.best.clone(), nil
+}
+
+// observe reports the outcome of a negotiation to the observer, if any.
+func (c *Negotiator) observe(n *negotiation) {
+	if c.opts.observer == nil {
+		return
+	}
+
+	if n.best == nil {
+		c.opts.observer.OnNoMatch(c.headerName)
+
+		return
+	}
+
+	c.opts.observer.OnMatch(c.headerName, n.priorities[n.best.Index].Value, n.best.Quality)
+}
+
+// negotiation holds the intermediate results of a negotiation.
+type negotiation struct {
+	// priorities are the parsed server priorities.
+	priorities []*Header
+	// matches holds the most specific match per priority, including rejected ones.
+	matches []*matchResult
+	// best is the winning match, nil if no priority is acceptable.
+	best *matchResult
+}
+
+// bestHeader returns the winning priority annotated with how it matched.
+func (n *negotiation) bestHeader() *Header {
+	best := n.priorities[n.best.Index]
+	best.MatchedVia = n.best.Via
+
+	return best
+}
+
+// negotiate parses the header and priorities and resolves the winning match,
+// falling back to the identity coding if enabled, see WithImplicitIdentity.
+// If serverQuality is set, q parameters of priorities are kept as server preference.
+func (c *Negotiator) negotiate(header string, priorities []WeightedPriority, strict, serverQuality bool) (*negotiation, error) {
+	n, headers, err := c.evaluate(header, priorities, strict, serverQuality, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if n.best == nil && c.opts.implicitIdentity {
+		n.priorities, n.best = c.identityFallback(headers, n.priorities)
+		if n.best != nil {
+			n.matches = append(n.matches, n.best)
+		}
+	}
+	if n.best != nil {
+		c.restoreCase(n.priorities[n.best.Index], n.best.Accept)
+		c.restoreClientCase(n.priorities[n.best.Index], n.best.Accept)
+	}
+
+	return n, nil
+}
+
+// evaluate parses the header and priorities and matches them, returning the
+// negotiation and the parsed header elements. Wildcard priorities are expanded
+// into the client types they cover if expand is set; otherwise they are matched
+// as given, e.g. to rate a single candidate.
+func (c *Negotiator) evaluate(header string, priorities []WeightedPriority, strict, serverQuality, expand bool) (*negotiation, []*Header, error) {
+	if len(priorities) == 0 {
+		return nil, nil, ErrEmptyPriorities
 	}
 
 	// Parse accept headers once (performance critical)
 	acceptedHeaders, err := c.parseAcceptHeaders(header, strict)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if c.opts.rejectWildcardOnly && wildcardOnly(acceptedHeaders) {
+		return nil, nil, ErrWildcardOnly
 	}
 
-	// Parse priorities
-	acceptedPriorities := make([]*Header, 0, len(priorities))
-	for _, p := range priorities {
-		acc, err := c.factory(p)
-		if err != nil {
-			if strict {
-				return nil, err
-			}
+	acceptedPriorities, err := c.parsePriorities(priorities, strict || c.opts.strictPriorities, serverQuality)
+	if err != nil {
+		return nil, nil, err
+	}
 
-			continue
+	if expand {
+		acceptedPriorities = c.expandWildcards(acceptedPriorities, acceptedHeaders)
+	}
+	matches := c.reduceMatches(c.findMatches(acceptedHeaders, acceptedPriorities))
+
+	return &negotiation{
+		priorities: acceptedPriorities,
+		matches:    matches,
+		best:       c.selectBest(matches, acceptedPriorities),
+	}, acceptedHeaders, nil
+}
+
+// identityFallback returns the match of the identity coding, appended to the
+// priorities if absent, for a header under which no priority is acceptable. A
+// header naming neither identity nor "*" accepts identity implicitly (RFC 7231,
+// section 5.3.4); otherwise the quality of that element applies, so "identity;q=0"
+// or "*;q=0" still rejects it. Returns a nil match if identity is not acceptable.
+func (c *Negotiator) identityFallback(headers, priorities []*Header) ([]*Header, *matchResult) {
+	index := slices.IndexFunc(priorities, func(p *Header) bool {
+		return p.Type == identityCoding
+	})
+	if index < 0 {
+		identity, err := c.parseElement(identityCoding)
+		if err != nil || !c.allowed(identity) {
+			return priorities, nil
 		}
-		acceptedPriorities = append(acceptedPriorities, acc)
+		identity.priorityIndex = -1
+		index = len(priorities)
+		priorities = append(priorities, identity)
+	}
+
+	matches := c.reduceMatches(c.findMatches(headers, priorities[index:index+1]))
+	if len(matches) == 0 {
+		return priorities, &matchResult{Quality: 1, Index: index, Via: MatchImplicit}
 	}
 
-	matches := c.findMatches(acceptedHeaders, acceptedPriorities)
-	specificMatches := c.reduceMatches(matches)
+	match := matches[0]
+	if match.Quality <= 0 {
+		return priorities, nil
+	}
+	match.Index = index
+
+	return priorities, match
+}
 
-	if len(specificMatches) == 0 {
-		return nil, ErrNoMatch
+// selectBest returns the best acceptable match, the first of rankMatches.
+// Returns nil if no match is acceptable.
+func (c *Negotiator) selectBest(matches []*matchResult, priorities []*Header) *matchResult {
+	ranked := c.rankMatches(matches, priorities)
+	if len(ranked) == 0 {
+		return nil
 	}
 
-	sort.Slice(specificMatches, func(i, j int) bool {
-		mi, mj := specificMatches[i], specificMatches[j]
+	return ranked[0]
+}
+
+// rankMatches returns the acceptable matches (q > 0) best first: by highest
+// quality, preferring the more exact of priorities that differ only in parameters
+// (see dropLessExact) and breaking remaining ties by the tie-break default, weight,
+// cost and priority order (or client order first, see WithClientPreferenceWins),
+// or in the order of the custom comparator.
+func (c *Negotiator) rankMatches(matches []*matchResult, priorities []*Header) []*matchResult {
+	acceptable := make([]*matchResult, 0, len(matches))
+	for _, match := range matches {
+		if match.Quality > 0 {
+			acceptable = append(acceptable, match)
+		}
+	}
+
+	if len(acceptable) == 0 {
+		return nil
+	}
+
+	if c.comparator != nil {
+		c.sortByComparator(acceptable, priorities)
+
+		return acceptable
+	}
+
+	acceptable = dropLessExact(acceptable, priorities)
+	tiebreak := c.tiebreakDefault()
+	sort.Slice(acceptable, func(i, j int) bool {
+		mi, mj := acceptable[i], acceptable[j]
 		if mi.Quality != mj.Quality {
 			return mi.Quality > mj.Quality
 		}
+		if mi.Fallback != mj.Fallback {
+			return !mi.Fallback
+		}
+		if c.opts.clientPreferenceWins && mi.Accept.originalIndex != mj.Accept.originalIndex {
+			return mi.Accept.originalIndex < mj.Accept.originalIndex
+		}
+		if di, dj := priorities[mi.Index].NormalizedValue == tiebreak, priorities[mj.Index].NormalizedValue == tiebreak; di != dj {
+			return di
+		}
+		if wi, wj := priorities[mi.Index].weight, priorities[mj.Index].weight; wi != wj {
+			return wi > wj
+		}
+		if ci, cj := priorities[mi.Index].cost, priorities[mj.Index].cost; ci != cj {
+			return ci < cj
+		}
 
 		return mi.Index < mj.Index
 	})
 
-	bestMatch := specificMatches[0]
+	return acceptable
+}
+
+// dropLessExact removes matches shadowed by a more exact priority of the same type:
+// among equally acceptable priorities that differ only in parameters, the one with
+// fewer parameters the client element did not ask for is kept, so a bare
+// application/json request prefers application/json over application/json;charset=utf-8.
+func dropLessExact(matches []*matchResult, priorities []*Header) []*matchResult {
+	return slices.DeleteFunc(slices.Clone(matches), func(m *matchResult) bool {
+		for _, other := range matches {
+			if other.Quality == m.Quality && other.Fallback == m.Fallback &&
+				priorities[other.Index].Type == priorities[m.Index].Type &&
+				unrequestedParams(priorities[other.Index], other.Accept) < unrequestedParams(priorities[m.Index], m.Accept) {
+				return true
+			}
+		}
 
-	return acceptedPriorities[bestMatch.Index], nil
+		return false
+	})
 }
 
-// GetOrderedElements returns all accept header elements ordered by quality.
-func (c *Negotiator) GetOrderedElements(header string) ([]*Header, error) {
-	if header == "" {
-		return nil, &InvalidArgumentError{Message: "the header string should not be empty"}
+// unrequestedParams returns how many parameters of the priority the client element
+// does not specify, disregarding ignored parameters.
+func unrequestedParams(priority, accept *Header) int {
+	count := 0
+	for k := range priority.Parameters {
+		if _, ok := accept.Parameters[k]; !ok && !ignoredParams[k] {
+			count++
+		}
 	}
 
+	return count
+}
+
+// tiebreakDefault returns the normalized value of the tie-break default priority,
+// or "" if none is set or it is invalid.
+func (c *Negotiator) tiebreakDefault() string {
+	if c.opts.tiebreakDefault == "" {
+		return ""
+	}
+
+	h, err := c.parseElement(c.opts.tiebreakDefault)
+	if err != nil {
+		return ""
+	}
+
+	return h.NormalizedValue
+}
+
+// sortByComparator orders acceptable matches with the custom comparator.
+// The comparator sees each priority with its resolved quality and its position
+// in the priority list as original index.
+func (c *Negotiator) sortByComparator(acceptable []*matchResult, priorities []*Header) {
+	// Sort by index first so the stable sort keeps priority order for ties
+	slices.SortFunc(acceptable, func(a, b *matchResult) int {
+		return cmp.Compare(a.Index, b.Index)
+	})
+
+	views := make(map[*matchResult]*Header, len(acceptable))
+	for _, match := range acceptable {
+		view := *priorities[match.Index]
+		view.Quality = match.Quality
+		view.originalIndex = match.Index
+		views[match] = &view
+	}
+
+	slices.SortStableFunc(acceptable, func(a, b *matchResult) int {
+		return c.comparator(views[a], views[b])
+	})
+}
+
+// resolveEmptyHeader returns the header to negotiate with. A header that is empty
+// after trimming is replaced by the configured empty header, or rejected with
+// ErrEmptyHeader if there is none.
+func (c *Negotiator) resolveEmptyHeader(header string) (string, error) {
+	if strings.TrimSpace(header) != "" {
+		return header, nil
+	}
+
+	if c.opts.emptyHeader == "" {
+		return "", ErrEmptyHeader
+	}
+
+	return c.opts.emptyHeader, nil
+}
+
+// QualityOf returns the quality the client gives candidate under the accept
+// header: the quality of the most specific element matching it, following the
+// same wildcard and specificity rules as Negotiate, e.g. 0.8 for "text/html"
+// under "text/*;q=0.8, */*;q=0.1". It returns 0 if candidate is rejected or
+// matches no element, or if it is invalid in non-strict mode.
+// It is not reported to the observer.
+func (c *Negotiator) QualityOf(accept, candidate string, strict bool) (float64, error) {
+	n, _, err := c.evaluate(accept, unweighted([]string{candidate}), strict, false, false)
+	if err != nil {
+		return 0, err
+	}
+	if n.best == nil {
+		return 0, nil
+	}
+
+	return n.best.Quality, nil
+}
+
+// Acceptable reports whether the client accepts candidate with a positive quality,
+// i.e. whether candidate would be selected were it the only priority.
+// It is not reported to the observer.
+func (c *Negotiator) Acceptable(header, candidate string, strict bool) (bool, error) {
+	q, err := c.QualityOf(header, candidate, strict)
+	if err != nil {
+		return false, err
+	}
+
+	return q > 0, nil
+}
+
+// StillAcceptable reports whether a previously negotiated value, such as the
+// Content-Type of a cached response, is still acceptable under a new header.
+// It lets a cache serve a stored representation without negotiating again;
+// whether chosen would still win against other priorities is not considered.
+func (c *Negotiator) StillAcceptable(header, chosen string, strict bool) (bool, error) {
+	return c.Acceptable(header, chosen, strict)
+}
+
+// Rejected returns the priorities the client does not accept, in priority order:
+// those matched only by ranges with q=0 and those no range matches at all.
+// Invalid priorities are skipped, as in non-strict negotiation.
+func (c *Negotiator) Rejected(header string, priorities []string) ([]string, error) {
+	n, _, err := c.evaluate(header, unweighted(priorities), false, false, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// A priority is accepted if it, or a client type it was expanded into, is
+	// matched with a positive quality.
+	accepted := make(map[int]bool, len(n.matches))
+	for _, match := range n.matches {
+		if match.Quality > 0 {
+			accepted[n.priorities[match.Index].priorityIndex] = true
+		}
+	}
+
+	rejected := make([]string, 0, len(priorities)-len(accepted))
+	reported := make(map[int]bool)
+	for _, priority := range n.priorities {
+		if i := priority.priorityIndex; !accepted[i] && !reported[i] {
+			reported[i] = true
+			rejected = append(rejected, priorities[i])
+		}
+	}
+
+	return rejected, nil
+}
+
+// GetOrderedElements returns all accept header elements ordered by quality.
+func (c *Negotiator) GetOrderedElements(header string) ([]*Header, error) {
 	// Parse once (performance critical)
 	elements, err := c.parseAcceptHeaders(header, false)
 	if err != nil {
 		return nil, err
 	}
 
-	sort.Slice(elements, func(i, j int) bool {
-		if elements[i].Quality != elements[j].Quality {
-			return elements[i].Quality > elements[j].Quality
-		}
+	c.restoreCase(elements...)
+	c.sortElements(elements)
+
+	return elements, nil
+}
 
-		return elements[i].originalIndex < elements[j].originalIndex
+// ElementStats summarizes the elements of a parsed header, see GetOrderedElementsWithStats.
+type ElementStats struct {
+	// HasWildcard reports whether any element is a range, such as "*/*", "text/*",
+	// "application/*+json" or "*", whatever its quality.
+	HasWildcard bool
+	// HasFullWildcard reports whether any element is the full wildcard "*/*", or
+	// "*" for headers other than Accept, whatever its quality.
+	HasFullWildcard bool
+}
+
+// GetOrderedElementsWithStats returns the elements ordered like GetOrderedElements
+// together with a summary of them, e.g. for a cache to decide whether to key on
+// the whole header or on a normalized subset when the client sent wildcards.
+func (c *Negotiator) GetOrderedElementsWithStats(header string) ([]*Header, ElementStats, error) {
+	elements, err := c.GetOrderedElements(header)
+	if err != nil {
+		return nil, ElementStats{}, err
+	}
+
+	var stats ElementStats
+	for _, e := range elements {
+		stats.HasWildcard = stats.HasWildcard || strings.Contains(e.Type, "*")
+		stats.HasFullWildcard = stats.HasFullWildcard || isFullWildcard(e)
+	}
+
+	return elements, stats, nil
+}
+
+// GetOrderedElementsLenient returns the valid accept header elements ordered by
+// quality like GetOrderedElements, together with an error for every element that
+// was skipped or recovered from, such as a malformed q parameter that was ignored.
+// Each error names the position and value of the element and wraps the parse error.
+// An empty header without an empty header default yields no elements and ErrEmptyHeader.
+func (c *Negotiator) GetOrderedElementsLenient(header string) ([]*Header, []error) {
+	var skipped []error
+	elements, err := c.parseAcceptHeadersReporting(header, false, func(err error) {
+		skipped = append(skipped, err)
 	})
+	if err != nil {
+		return nil, []error{err}
+	}
 
-	return elements, nil
+	c.restoreCase(elements...)
+	c.sortElements(elements)
+
+	return elements, skipped
+}
+
+// sortElements orders elements by the comparator, keeping header order for ties.
+func (c *Negotiator) sortElements(elements []*Header) {
+	compare := c.comparator
+	if compare == nil {
+		compare = DefaultComparator
+	}
+	slices.SortStableFunc(elements, compare)
+}
+
+// Normalize returns a canonical form of the header, suitable for cache keys.
+// Elements are ordered as by GetOrderedElements, types are lowercased (language
+// tags use BCP 47 casing), parameters are sorted and q=1 is omitted, so headers
+// that mean the same thing normalize to byte-identical output.
+func (c *Negotiator) Normalize(header string) (string, error) {
+	elements, err := c.GetOrderedElements(header)
+	if err != nil {
+		return "", err
+	}
+
+	parts := make([]string, 0, len(elements))
+	for _, e := range elements {
+		parts = append(parts, formatElement(e))
+	}
+
+	return strings.Join(parts, ", "), nil
+}
+
+// formatElement formats a header element as its normalized value followed by
+// its quality, omitting the default quality of 1.
+func formatElement(h *Header) string {
+	if h.Quality == 1.0 {
+		return h.NormalizedValue
+	}
+
+	return h.NormalizedValue + "; q=" + strconv.FormatFloat(h.Quality, 'f', -1, 64)
 }
 
 // parseAcceptHeaders parses an Accept* header string into Header instances.
 // Parses once to avoid redundant parsing (performance critical).
+// Duplicate ranges are always collapsed, see dedupElements.
+// In strict mode, headers containing control characters are rejected; otherwise
+// a malformed q parameter is ignored, so the element gets the default quality.
+// An empty header is replaced as configured by WithEmptyHeader; elements of
+// such a substitute carry no offsets.
 func (c *Negotiator) parseAcceptHeaders(header string, strict bool) ([]*Header, error) {
-	parts, err := parseHeader(header)
+	return c.parseAcceptHeadersReporting(header, strict, func(error) {})
+}
+
+// parseAcceptHeadersReporting parses like parseAcceptHeaders, passing the error of
+// every element skipped or recovered in non-strict mode to report.
+func (c *Negotiator) parseAcceptHeadersReporting(header string, strict bool, report func(error)) ([]*Header, error) {
+	resolved, err := c.resolveEmptyHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	substituted := resolved != header
+	header = resolved
+
+	if strict {
+		if err := validateFieldValue(header); err != nil {
+			return nil, markHeaderError(err)
+		}
+		if hasEmptyElement(header, c.opts.commaDecimalTolerance) {
+			return nil, markHeaderError(&InvalidHeaderError{Header: header})
+		}
+	}
+
+	parts, err := parseHeader(header, c.opts.commaDecimalTolerance)
 	if err != nil {
 		if strict {
-			return nil, err
+			return nil, markHeaderError(err)
 		}
+		report(markHeaderError(err))
 
 		return []*Header{}, nil
 	}
 
 	headers := make([]*Header, 0, len(parts))
 	for i, part := range parts {
-		h, err := c.factory(part)
+		h, err := c.parsePart(part, i, strict, substituted, report)
+		if err != nil {
+			if strict {
+				return nil, markHeaderError(err)
+			}
+			report(markHeaderError(fmt.Errorf("element %d %q: %w", i, part.value, err)))
+
+			continue
+		}
+		headers = append(headers, h)
+	}
+
+	return dedupElements(headers), nil
+}
+
+// parsePart parses the i-th element of a header. In strict mode empty parameters
+// (";;") are rejected; otherwise they are skipped, and a malformed q parameter is
+// clamped to the nearest qvalue if it is a plain decimal, or ignored otherwise,
+// with its error, naming the element, passed to report. Elements of a
+// substituted empty header get no offsets.
+func (c *Negotiator) parsePart(part headerPart, i int, strict, substituted bool, report func(error)) (*Header, error) {
+	if strict && hasEmptyParameter(part.value) {
+		return nil, &InvalidHeaderError{Header: part.value}
+	}
+	if c.maxParameters > 0 && countParameters(part.value) > c.maxParameters {
+		return nil, &TooManyParametersError{Header: part.value, Max: c.maxParameters}
+	}
+
+	h, err := c.parseElement(part.value)
+	if qualityErr := (*InvalidQualityError)(nil); c.opts.commaDecimalTolerance && errors.As(err, &qualityErr) {
+		// Read a comma decimal as a dot decimal
+		h, err = c.parseElement(dotDecimalQuality(part.value))
+		if h != nil {
+			h.Value = part.value
+		}
+	}
+	if qualityErr := (*InvalidQualityError)(nil); !strict && errors.As(err, &qualityErr) {
+		report(markHeaderError(fmt.Errorf("element %d %q: %w", i, part.value, err)))
+		if clamped, ok := clampQuality(part.value); ok {
+			// Read an out-of-range or too precise quality as the nearest qvalue
+			h, err = c.parseElement(clamped)
+		} else {
+			// Ignore a malformed quality and use the default
+			h, err = c.parseElement(stripQuality(part.value))
+		}
+		if h != nil {
+			h.Value = part.value
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if c.opts.hasDefaultQuality && !h.QualityExplicit {
+		h.Quality = c.opts.defaultQuality
+	}
+	h.originalIndex = i
+	if !substituted {
+		h.Start = part.start
+		h.End = part.end
+	}
+
+	return h, nil
+}
+
+// dedupElements collapses elements with the same normalized type and parameters,
+// keeping the one with the highest quality (the earliest among equal qualities).
+// Remaining elements keep their original order.
+func dedupElements(headers []*Header) []*Header {
+	kept := make(map[string]int, len(headers))
+	result := headers[:0]
+
+	for _, h := range headers {
+		i, ok := kept[h.NormalizedValue]
+		if !ok {
+			kept[h.NormalizedValue] = len(result)
+			result = append(result, h)
+
+			continue
+		}
+
+		if h.Quality > result[i].Quality {
+			result[i] = h
+		}
+	}
+
+	return result
+}
+
+// parseElement parses a single header element or priority, resolves type
+// aliases and applies the custom type normalizer, if any. The normalized type
+// replaces Type and its parts, while Value keeps the original input.
+func (c *Negotiator) parseElement(value string) (*Header, error) {
+	h, err := c.parseValue(value)
+	if err != nil {
+		return nil, err
+	}
+
+	typ := h.Type
+	if canonical, ok := c.aliases[typ]; ok {
+		typ = canonical
+	}
+	if c.opts.typeNormalizer != nil {
+		typ = c.opts.typeNormalizer(typ)
+	}
+	if typ == h.Type {
+		h.givenType = givenType(value, h.Type)
+
+		return h, nil
+	}
+
+	// Parse the normalized type alone to derive its parts
+	normalized, err := c.factory(typ)
+	if err != nil {
+		return nil, err
+	}
+
+	h.Type = normalized.Type
+	h.BasePart = normalized.BasePart
+	h.SubPart = normalized.SubPart
+	h.ScriptPart = normalized.ScriptPart
+	h.NormalizedValue = buildNormalizedValue(normalized.NormalizedValue, h.Parameters)
+
+	return h, nil
+}
+
+// givenType returns the type of value as given if it differs from typ only in
+// case, or "" otherwise.
+func givenType(value, typ string) string {
+	given, _, _ := strings.Cut(value, ";")
+	given = strings.TrimSpace(given)
+	if given == typ || !strings.EqualFold(given, typ) {
+		return ""
+	}
+
+	return given
+}
+
+// restoreCase sets the type of each header back to the casing it was given in
+// if WithPreserveCase is enabled.
+func (c *Negotiator) restoreCase(headers ...*Header) {
+	if !c.opts.preserveCase {
+		return
+	}
+
+	for _, h := range headers {
+		if h != nil && h.givenType != "" {
+			h.Type = h.givenType
+		}
+	}
+}
+
+// restoreClientCase sets the type of the winning priority to the casing of the
+// client element it matched, if that element names the same type and
+// WithPreserveCase is enabled. A priority matched through a range keeps its own
+// casing, as the client did not spell out its type.
+func (c *Negotiator) restoreClientCase(best, accept *Header) {
+	if c.opts.preserveCase && accept != nil && strings.EqualFold(accept.Type, best.Type) {
+		best.Type = accept.Type
+	}
+}
+
+// parseValue parses a value with the factory. In RFC 7231 strict mode the
+// accept-ext parameters following the weight are kept out of the factory and
+// recorded as Extensions instead.
+func (c *Negotiator) parseValue(value string) (*Header, error) {
+	if !c.opts.rfc7231Strict {
+		return c.factory(value)
+	}
+
+	media, extensions, err := cutAcceptExtensions(value)
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := c.factory(media)
+	if err != nil {
+		return nil, err
+	}
+	h.Value = value
+	h.Extensions = extensions
+
+	return h, nil
+}
+
+// ValidatePriorities parses every priority as Negotiate would with this negotiator's
+// options and reports all invalid entries, so a misconfigured priority list can be
+// detected at startup rather than at request time. Each error names the position
+// and value of the priority and wraps the parse error.
+func (c *Negotiator) ValidatePriorities(priorities []string) error {
+	if len(priorities) == 0 {
+		return ErrEmptyPriorities
+	}
+
+	var errs []error
+	for i, p := range priorities {
+		h, err := c.parseElement(p)
+		if err == nil && !c.allowed(h) {
+			err = ErrNotAllowed
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("priority %d %q: %w", i, p, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// givenPriority returns the priority of priorities the negotiated best stems
+// from, such as the wildcard priority a concrete client type was expanded from,
+// or the value of best if it was added by negotiation, like an implicit identity.
+func givenPriority(best *Header, priorities []string) string {
+	if best.priorityIndex < 0 {
+		return best.Value
+	}
+
+	return priorities[best.priorityIndex]
+}
+
+// allowed reports whether a priority passes the allowlist, if any.
+func (c *Negotiator) allowed(h *Header) bool {
+	return c.opts.allowlist == nil || c.opts.allowlist[h.Type]
+}
+
+// parsePriorities parses the server priorities into Header instances.
+// Priorities not passing the allowlist are skipped.
+// Unless serverQuality is set, a q parameter on a priority carries no meaning
+// and is reset to 1.0 so it never affects the resolved quality.
+func (c *Negotiator) parsePriorities(priorities []WeightedPriority, strict, serverQuality bool) ([]*Header, error) {
+	headers := make([]*Header, 0, len(priorities))
+	for i, p := range priorities {
+		h, err := c.parseElement(p.Value)
 		if err != nil {
 			if strict {
 				return nil, err
@@ -140,21 +995,134 @@ func (c *Negotiator) parseAcceptHeaders(header string, strict bool) ([]*Header,
 
 			continue
 		}
-		h.originalIndex = i
+		if !c.allowed(h) {
+			continue
+		}
+		if !serverQuality {
+			h.Quality = 1.0
+		}
+		h.weight = p.Weight
+		h.cost = p.Cost
+		h.priorityIndex = i
 		headers = append(headers, h)
 	}
 
 	return headers, nil
 }
 
+// expandWildcards replaces each wildcard priority, the full wildcard ("*/*", or "*"
+// for other headers) or a media type wildcard such as "application/*", by the
+// concrete client elements of positive quality it covers, most preferred first,
+// followed by the wildcard itself, so a server able to produce anything (of a type)
+// gets the type the client prefers most. The expanded priorities take the weight,
+// cost and quality of the wildcard and are subject to the allowlist.
+func (c *Negotiator) expandWildcards(priorities, accepted []*Header) []*Header {
+	if !slices.ContainsFunc(priorities, isWildcardPriority) {
+		return priorities
+	}
+
+	concrete := make([]*Header, 0, len(accepted))
+	for _, a := range accepted {
+		if a.Quality > 0 && !strings.Contains(a.Type, "*") {
+			concrete = append(concrete, a)
+		}
+	}
+	slices.SortStableFunc(concrete, func(a, b *Header) int {
+		return cmp.Compare(b.Quality, a.Quality)
+	})
+
+	expanded := make([]*Header, 0, len(priorities)+len(concrete))
+	for _, p := range priorities {
+		if !isWildcardPriority(p) {
+			expanded = append(expanded, p)
+
+			continue
+		}
+
+		for _, a := range concrete {
+			if !isFullWildcard(p) && a.BasePart != p.BasePart {
+				continue
+			}
+
+			h := a.clone()
+			if !c.allowed(h) {
+				continue
+			}
+			h.Value = h.NormalizedValue
+			h.Quality = p.Quality
+			h.QualityExplicit = false
+			h.Extensions = nil
+			h.Start, h.End = 0, 0
+			h.weight = p.weight
+			h.cost = p.cost
+			h.priorityIndex = p.priorityIndex
+			expanded = append(expanded, h)
+		}
+		expanded = append(expanded, p)
+	}
+
+	return expanded
+}
+
+// wildcardOnly reports whether the client accepts at least one element and all
+// elements it accepts with a positive quality are full wildcards.
+func wildcardOnly(accepted []*Header) bool {
+	found := false
+	for _, a := range accepted {
+		if a.Quality <= 0 {
+			continue
+		}
+		if !isFullWildcard(a) {
+			return false
+		}
+		found = true
+	}
+
+	return found
+}
+
+// isFullWildcard reports whether h is the full wildcard "*/*", or "*" for headers
+// other than Accept.
+func isFullWildcard(h *Header) bool {
+	return h.Type == "*/*" || h.Type == "*"
+}
+
+// isWildcardPriority reports whether h is the full wildcard or a media type
+// wildcard such as "application/*". Suffix ranges such as "application/*+json"
+// are not.
+func isWildcardPriority(h *Header) bool {
+	return isFullWildcard(h) || (h.SubPart == "*" && h.BasePart != "")
+}
+
+// moreSpecific reports whether match a is more specific than b: it has a higher
+// score, matches more parameters at an equal score, or is otherwise equal
+// without being a degraded fallback match.
+func moreSpecific(a, b *matchResult) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+
+	if a.Params != b.Params {
+		return a.Params > b.Params
+	}
+
+	return !a.Fallback && b.Fallback
+}
+
 // findMatches finds all matches between headers and priorities.
 // Both arguments are already parsed Header instances (no redundant parsing).
+// A wildcard priority only matches client ranges, as the concrete client
+// elements it covers are expanded into priorities of their own.
 func (c *Negotiator) findMatches(headers, priorities []*Header) []*matchResult {
 	matches := make([]*matchResult, 0)
 
 	for i, priority := range priorities {
 		for _, accept := range headers {
-			if match := c.matcher(accept, priority, i); match != nil {
+			if isWildcardPriority(priority) && !strings.Contains(accept.Type, "*") {
+				continue
+			}
+			if match := c.matcher(accept, priority, i, &c.opts); match != nil {
+				match.Accept = accept
 				matches = append(matches, match)
 			}
 		}
@@ -163,12 +1131,15 @@ func (c *Negotiator) findMatches(headers, priorities []*Header) []*matchResult {
 	return matches
 }
 
-// reduceMatches reduces matches to the best match per priority index.
+// reduceMatches reduces matches to the most specific match per priority index.
+// The quality of a priority is taken from its most specific matching range
+// (e.g. type/subtype;param > type/subtype > type/* > */*), so a priority whose most specific range
+// has q=0 is rejected even if a less specific range accepts it.
 func (c *Negotiator) reduceMatches(matches []*matchResult) []*matchResult {
 	bestByIndex := make(map[int]*matchResult)
 
 	for _, match := range matches {
-		if existing, ok := bestByIndex[match.Index]; !ok || existing.Score < match.Score {
+		if existing, ok := bestByIndex[match.Index]; !ok || moreSpecific(match, existing) {
 			bestByIndex[match.Index] = match
 		}
 	}
@@ -1,6 +1,9 @@
 package negotiation
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -126,6 +129,30 @@ func TestNegotiator_Negotiate_Language(t *testing.T) {
 			expectedBase: "en",
 			expectedSub:  "us",
 		},
+		{
+			name:         "priority with region and variant",
+			acceptHeader: "de-CH",
+			priorities:   []string{"de-CH-1901"},
+			expectedType: "de-ch-1901",
+			expectedBase: "de",
+			expectedSub:  "ch",
+		},
+		{
+			name:         "variant-only tag matches its prefix",
+			acceptHeader: "sl-rozaj-biske",
+			priorities:   []string{"sl-rozaj"},
+			expectedType: "sl-rozaj",
+			expectedBase: "sl",
+			expectedSub:  "rozaj",
+		},
+		{
+			name:         "priority with region and posix variant",
+			acceptHeader: "fr, en-US;q=0.9",
+			priorities:   []string{"de", "en-US-posix"},
+			expectedType: "en-us-posix",
+			expectedBase: "en",
+			expectedSub:  "us",
+		},
 	}
 
 	for _, tt := range tests {
@@ -151,6 +178,249 @@ func TestNegotiator_Negotiate_Charset(t *testing.T) {
 	assert.Equal(t, "", result.SubPart)
 }
 
+func TestNegotiator_Negotiate_NoClientPreference(t *testing.T) {
+	tests := []struct {
+		name         string
+		negotiator   *Negotiator
+		acceptHeader string
+		priorities   []string
+	}{
+		{"media full wildcard", NewMediaNegotiator(), "*/*", []string{"application/json", "text/html", "image/png"}},
+		{"media bare wildcard", NewMediaNegotiator(), "*", []string{"text/html", "application/json"}},
+		{"media equal quality", NewMediaNegotiator(), "text/html;q=0.8, application/json;q=0.8", []string{"application/json", "text/html"}},
+		{"language wildcard", NewLanguageNegotiator(), "*", []string{"fr", "en"}},
+		{"charset wildcard", NewCharsetNegotiator(), "*", []string{"iso-8859-1", "utf-8"}},
+		{"encoding wildcard", NewEncodingNegotiator(), "*", []string{"br", "gzip"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 10 {
+				result, err := tt.negotiator.Negotiate(tt.acceptHeader, tt.priorities, false)
+				require.NoError(t, err)
+				assert.Equal(t, tt.priorities[0], result.Type, "first priority wins")
+			}
+		})
+	}
+}
+
+func TestNegotiator_NegotiateChain(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+	priorities := []string{"application/json", "text/html"}
+
+	tests := []struct {
+		name      string
+		accepts   []string
+		strict    bool
+		expected  string
+		expectErr error
+	}{
+		{"first header matches", []string{"text/html", "application/json"}, false, "text/html", nil},
+		{"falls back to second", []string{"image/png", "application/json"}, false, "application/json", nil},
+		{"first match wins over better later match", []string{"text/html;q=0.1", "application/json"}, false, "text/html", nil},
+		{"empty header falls through", []string{"", "text/html"}, false, "text/html", nil},
+		{"invalid header falls through in strict mode", []string{"text/html;q=abc", "application/json"}, true, "application/json", nil},
+		{"all fail to match", []string{"image/png", "image/gif"}, false, "", ErrNoAcceptableMatch},
+		{"no headers", nil, false, "", ErrEmptyHeader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := negotiator.NegotiateChain(tt.accepts, priorities, tt.strict)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+				assert.Nil(t, result)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Value)
+		})
+	}
+}
+
+func TestNegotiator_NegotiateChain_Errors(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	// All no-match failures report the sentinel itself.
+	_, err := negotiator.NegotiateChain([]string{"image/png", "image/gif"}, []string{"text/html"}, false)
+	assert.Same(t, ErrNoAcceptableMatch, err)
+
+	// Other failures are joined.
+	_, err = negotiator.NegotiateChain([]string{"image/png", "text/html;q=abc"}, []string{"text/html"}, true)
+	require.ErrorIs(t, err, ErrNoAcceptableMatch)
+	var qualityErr *InvalidQualityError
+	require.ErrorAs(t, err, &qualityErr)
+
+	_, err = negotiator.NegotiateChain([]string{"text/html"}, nil, false)
+	require.ErrorIs(t, err, ErrEmptyPriorities)
+}
+
+func TestNegotiator_NegotiateChain_Observer(t *testing.T) {
+	observer := &recordingObserver{}
+	negotiator := NewMediaNegotiator(WithObserver(observer))
+
+	_, err := negotiator.NegotiateChain([]string{"image/png", "text/html;q=0.5"}, []string{"text/html"}, false)
+	require.NoError(t, err)
+
+	_, err = negotiator.NegotiateChain([]string{"image/png", "image/gif"}, []string{"text/html"}, false)
+	require.ErrorIs(t, err, ErrNoAcceptableMatch)
+
+	assert.Equal(t, []string{"Accept text/html"}, observer.matches)
+	assert.Equal(t, []float64{0.5}, observer.qualities)
+	assert.Equal(t, []string{"Accept"}, observer.noMatches)
+}
+
+func TestNegotiator_NegotiateServerQuality(t *testing.T) {
+	tests := []struct {
+		name         string
+		negotiator   *Negotiator
+		acceptHeader string
+		priorities   []string
+		expected     string
+		expectErr    error
+	}{
+		{"server preference decides client ties", NewMediaNegotiator(), "text/html, application/json", []string{"text/html;q=0.5", "application/json;q=1"}, "application/json", nil},
+		{"qualities are multiplied", NewMediaNegotiator(), "text/html, application/json;q=0.4", []string{"application/json;q=1", "text/html;q=0.5"}, "text/html", nil},
+		{"client quality can outweigh server quality", NewMediaNegotiator(), "text/html, application/json;q=0.6", []string{"application/json;q=1", "text/html;q=0.5"}, "application/json", nil},
+		{"omitted q means 1", NewMediaNegotiator(), "*/*", []string{"text/html;q=0.9", "application/json"}, "application/json", nil},
+		{"q=0 is never chosen", NewMediaNegotiator(), "text/html", []string{"text/html;q=0"}, "", ErrNoAcceptableMatch},
+		{"encodings", NewEncodingNegotiator(), "gzip, br", []string{"gzip;q=0.5", "br"}, "br", nil},
+		{"languages", NewLanguageNegotiator(), "en, fr;q=0.9", []string{"en;q=0.5", "fr"}, "fr", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.negotiator.NegotiateServerQuality(tt.acceptHeader, tt.priorities, true)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Type)
+		})
+	}
+
+	// Negotiate ignores q on priorities.
+	result, err := NewMediaNegotiator().Negotiate("text/html, application/json", []string{"text/html;q=0.5", "application/json;q=1"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "text/html", result.Type)
+}
+
+func TestNegotiator_Negotiate_LanguageWildcard(t *testing.T) {
+	tests := []struct {
+		name            string
+		acceptHeader    string
+		priorities      []string
+		expected        string
+		expectedQuality float64
+		expectErr       error
+	}{
+		{"only wildcard", "*", []string{"fr", "en"}, "fr", 1.0, nil},
+		{"explicit language preferred", "da;q=0.8, *;q=0.1", []string{"en", "da"}, "da", 0.8, nil},
+		{"falls back to anything", "da;q=0.8, *;q=0.1", []string{"en", "fr"}, "en", 0.1, nil},
+		{"wildcard with higher quality", "da;q=0.5, *", []string{"da", "en"}, "en", 1.0, nil},
+		{"explicit language wins over wildcard quality", "en;q=0.2, *", []string{"en"}, "en", 0.2, nil},
+		{"region range more specific than wildcard", "*, en-US;q=0", []string{"en-US", "en-GB"}, "en-gb", 1.0, nil},
+		{"wildcard excluded", "*;q=0, da", []string{"en", "fr"}, "", 0, ErrNoAcceptableMatch},
+	}
+
+	negotiator := NewLanguageNegotiator()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, trace, err := negotiator.NegotiateWithTrace(tt.acceptHeader, tt.priorities, true)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Type)
+			for _, p := range trace.Priorities {
+				if p.Selected {
+					assert.InDelta(t, tt.expectedQuality, p.Quality, 1e-9)
+				}
+			}
+		})
+	}
+}
+
+func TestNegotiator_Negotiate_EmptyHeader(t *testing.T) {
+	tests := []struct {
+		name       string
+		negotiator *Negotiator
+		header     string
+		priorities []string
+		expected   string
+		expectErr  error
+	}{
+		{"media", NewMediaNegotiator(), "", []string{"text/html"}, "", ErrEmptyHeader},
+		{"language", NewLanguageNegotiator(), " ", []string{"en"}, "", ErrEmptyHeader},
+		{"charset", NewCharsetNegotiator(), "", []string{"utf-8"}, "", ErrEmptyHeader},
+		{"TE", NewTENegotiator(), "", []string{"trailers"}, "", ErrEmptyHeader},
+		{"encoding means identity", NewEncodingNegotiator(), "", []string{"gzip", "identity"}, "identity", nil},
+		{"encoding blank means identity", NewEncodingNegotiator(), " \t", []string{"identity"}, "identity", nil},
+		{"encoding rejects other codings", NewEncodingNegotiator(), "", []string{"gzip", "br"}, "", ErrNoAcceptableMatch},
+		{"media as wildcard", NewMediaNegotiator(WithEmptyHeader("*")), "", []string{"application/json", "text/html"}, "application/json", nil},
+		{"language as wildcard", NewLanguageNegotiator(WithEmptyHeader("*")), "", []string{"fr", "en"}, "fr", nil},
+		{"encoding as error", NewEncodingNegotiator(WithEmptyHeader("")), "", []string{"identity"}, "", ErrEmptyHeader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.negotiator.Negotiate(tt.header, tt.priorities, true)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+				assert.Nil(t, result)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Type)
+		})
+	}
+}
+
+func TestNegotiator_GetOrderedElements_EmptyHeader(t *testing.T) {
+	elements, err := NewEncodingNegotiator().GetOrderedElements("")
+	require.NoError(t, err)
+	require.Len(t, elements, 1)
+	assert.Equal(t, "identity", elements[0].Type)
+
+	_, err = NewMediaNegotiator().GetOrderedElements("  ")
+	require.ErrorIs(t, err, ErrEmptyHeader)
+}
+
+func TestNegotiator_Negotiate_CharsetAliases(t *testing.T) {
+	negotiator := NewCharsetNegotiator()
+
+	tests := []struct {
+		name         string
+		acceptHeader string
+		priorities   []string
+		expected     string
+	}{
+		{"alias in header", "utf8", []string{"iso-8859-1", "utf-8"}, "utf-8"},
+		{"alias in priority", "iso-8859-1", []string{"utf-8", "latin1"}, "iso-8859-1"},
+		{"case insensitive alias", "Latin1;q=0.9, UTF8;q=0.5", []string{"utf-8", "iso-8859-1"}, "iso-8859-1"},
+		{"rejected via alias", "*, utf8;q=0", []string{"utf-8", "us-ascii"}, "us-ascii"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := negotiator.Negotiate(tt.acceptHeader, tt.priorities, true)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Type)
+		})
+	}
+}
+
 func TestNegotiator_Negotiate_Encoding(t *testing.T) {
 	negotiator := NewEncodingNegotiator()
 
@@ -162,6 +432,64 @@ func TestNegotiator_Negotiate_Encoding(t *testing.T) {
 	assert.Equal(t, "", result.SubPart)
 }
 
+func TestNewGenericNegotiator(t *testing.T) {
+	errUnknownFlavor := errors.New("unknown flavor")
+	flavors := map[string]string{"vanilla": "vanilla", "choc": "chocolate", "chocolate": "chocolate"}
+	negotiator := NewGenericNegotiator("Accept-Flavor", func(typ string) (string, error) {
+		if flavor, ok := flavors[typ]; ok {
+			return flavor, nil
+		}
+
+		return "", errUnknownFlavor
+	})
+
+	// Types are normalized by the parse function before matching.
+	result, err := negotiator.Negotiate("vanilla;q=0.5, CHOC", []string{"vanilla", "chocolate"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "chocolate", result.Value)
+	assert.Equal(t, MatchExact, result.MatchedVia)
+
+	// The wildcard is handled by the engine and ranked by quality.
+	result, err = negotiator.Negotiate("vanilla;q=0.2, *;q=0.5", []string{"vanilla", "chocolate"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "chocolate", result.Value)
+	assert.Equal(t, MatchFullWildcard, result.MatchedVia)
+
+	elements, err := negotiator.GetOrderedElements("vanilla;q=0.5, chocolate;q=0.9, *;q=0.1")
+	require.NoError(t, err)
+	require.Len(t, elements, 3)
+	assert.Equal(t, "chocolate", elements[0].Type)
+
+	// Parse errors surface in strict mode and skip the element otherwise.
+	_, err = negotiator.Negotiate("strawberry, vanilla", []string{"vanilla"}, true)
+	require.ErrorIs(t, err, errUnknownFlavor)
+	require.ErrorIs(t, err, ErrMalformedHeader)
+
+	result, err = negotiator.Negotiate("strawberry, vanilla", []string{"vanilla"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "vanilla", result.Value)
+
+	_, err = negotiator.Negotiate("vanilla;q=0", []string{"vanilla"}, true)
+	require.ErrorIs(t, err, ErrNoAcceptableMatch)
+}
+
+func TestNewGenericNegotiator_NilParseType(t *testing.T) {
+	negotiator := NewGenericNegotiator("Accept-Foo", nil)
+
+	result, err := negotiator.Negotiate("Bar;q=0.5, baz", []string{"bar", "baz"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "baz", result.Value)
+}
+
+func TestNewGenericNegotiator_Observer(t *testing.T) {
+	observer := &recordingObserver{}
+	negotiator := NewGenericNegotiator("Accept-Foo", nil, WithObserver(observer))
+
+	_, err := negotiator.Negotiate("bar", []string{"bar"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Accept-Foo bar"}, observer.matches)
+}
+
 func TestNegotiator_GetOrderedElements(t *testing.T) {
 	negotiator := NewMediaNegotiator()
 
@@ -184,15 +512,28 @@ func TestNegotiator_GetOrderedElements(t *testing.T) {
 			expectedLen:   3,
 			expectedOrder: []string{"text/html", "application/json", "text/plain"},
 		},
+		{
+			name:          "duplicate ranges keep highest quality",
+			header:        "text/html;q=0.3, application/json;q=0.5, text/html;q=0.7",
+			expectedLen:   2,
+			expectedOrder: []string{"text/html", "application/json"},
+		},
+		{
+			name:          "duplicate ranges with equal parameters",
+			header:        "text/html;level=1;charset=utf-8, text/html;charset=utf-8;level=1;q=0.9, text/html",
+			expectedLen:   2,
+			expectedOrder: []string{"text/html", "text/html"},
+		},
 		{
 			name:        "empty header",
 			header:      "",
 			expectError: true,
 		},
 		{
-			name:        "malformed header",
-			header:      `text/html;q="unclosed, application/json`,
-			expectedLen: 0,
+			name:          "malformed header",
+			header:        `text/html;q="unclosed, application/json`,
+			expectedLen:   1,
+			expectedOrder: []string{"text/html"},
 		},
 	}
 
@@ -264,6 +605,23 @@ func TestNegotiator_StrictMode_ParseError(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestNegotiator_EmptyListElements(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	for _, header := range []string{"application/json,", ",application/json", "application/json,,text/html"} {
+		t.Run(header, func(t *testing.T) {
+			result, err := negotiator.Negotiate(header, []string{"application/json"}, false)
+			require.NoError(t, err)
+			require.NotNil(t, result)
+			assert.Equal(t, "application/json", result.Type)
+
+			_, err = negotiator.Negotiate(header, []string{"application/json"}, true)
+			require.ErrorIs(t, err, ErrMalformedHeader)
+			assert.IsType(t, &InvalidHeaderError{}, err)
+		})
+	}
+}
+
 func TestNegotiator_ComplexMatching(t *testing.T) {
 	negotiator := NewMediaNegotiator()
 
@@ -327,3 +685,1462 @@ func TestNegotiator_EmptyAcceptHeader(t *testing.T) {
 	assert.Nil(t, elements)
 	assert.Equal(t, &InvalidArgumentError{Message: "the header string should not be empty"}, err)
 }
+
+func TestNegotiator_Negotiate_SentinelErrors(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	tests := []struct {
+		name         string
+		acceptHeader string
+		priorities   []string
+		expected     error
+	}{
+		{"empty priorities", "text/html", []string{}, ErrEmptyPriorities},
+		{"empty header", "", []string{"text/html"}, ErrEmptyHeader},
+		{"no acceptable match", "text/html", []string{"application/json"}, ErrNoAcceptableMatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := negotiator.Negotiate(tt.acceptHeader, tt.priorities, false)
+			require.ErrorIs(t, err, tt.expected)
+		})
+	}
+
+	// ErrNoMatch is kept as an alias of ErrNoAcceptableMatch.
+	_, err := negotiator.Negotiate("text/html", []string{"application/json"}, false)
+	assert.ErrorIs(t, err, ErrNoMatch)
+
+	// It is still an *InvalidArgumentError with the same message.
+	var argErr *InvalidArgumentError
+	require.ErrorAs(t, err, &argErr)
+	assert.Equal(t, "no matching header found", ErrNoMatch.Message)
+}
+
+func TestNegotiator_Negotiate_ErrorClassification(t *testing.T) {
+	tests := []struct {
+		name       string
+		negotiator *Negotiator
+		header     string
+		priorities []string
+		malformed  bool
+		noMatch    bool
+	}{
+		{"invalid media type", NewMediaNegotiator(), "invalid/header/format", []string{"text/html"}, true, false},
+		{"invalid media type among valid", NewMediaNegotiator(), "invalid/header/format, text/html", []string{"text/html"}, true, false},
+		{"invalid quality", NewMediaNegotiator(), "text/html;q=abc", []string{"text/html"}, true, false},
+		{"unclosed quote", NewMediaNegotiator(), `text/html;q="unclosed quote`, []string{"text/html"}, true, false},
+		{"control character", NewMediaNegotiator(), "text/html\r\nX: y", []string{"text/html"}, true, false},
+		{"only separators", NewMediaNegotiator(), " , ", []string{"text/html"}, true, false},
+		{"invalid language", NewLanguageNegotiator(), "en_US", []string{"en"}, true, false},
+		{"generic parse error", NewGenericNegotiator("Accept-Flavor", func(typ string) (string, error) {
+			if typ != "vanilla" {
+				return "", errors.New("unknown flavor")
+			}
+
+			return typ, nil
+		}), "mint", []string{"vanilla"}, true, false},
+		{"unsatisfiable", NewMediaNegotiator(), "image/png", []string{"text/html"}, false, true},
+		{"excluded", NewMediaNegotiator(), "text/html;q=0", []string{"text/html"}, false, true},
+		{"invalid priority", NewMediaNegotiator(), "text/html", []string{"invalid/priority/format"}, false, false},
+		{"empty header", NewMediaNegotiator(), "", []string{"text/html"}, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.negotiator.Negotiate(tt.header, tt.priorities, true)
+			require.Error(t, err)
+			assert.Equal(t, tt.malformed, errors.Is(err, ErrMalformedHeader), "malformed")
+			assert.Equal(t, tt.noMatch, errors.Is(err, ErrNoAcceptableMatch), "no match")
+		})
+	}
+
+	// The concrete error types are kept.
+	_, err := NewMediaNegotiator().Negotiate("invalid/header/format", []string{"text/html"}, true)
+	assert.IsType(t, &InvalidMediaTypeError{}, err)
+
+	// Lenient negotiation skips malformed elements, but reports them as malformed.
+	_, err = NewMediaNegotiator().Negotiate("invalid/header/format", []string{"text/html"}, false)
+	require.ErrorIs(t, err, ErrNoAcceptableMatch)
+
+	_, skipped := NewMediaNegotiator().GetOrderedElementsLenient("invalid/header/format, text/html")
+	require.Len(t, skipped, 1)
+	require.ErrorIs(t, skipped[0], ErrMalformedHeader)
+
+	// Priority parse errors are the server's and never classified as malformed headers.
+	err = NewMediaNegotiator().ValidatePriorities([]string{"invalid/priority/format"})
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrMalformedHeader)
+}
+
+func TestNegotiator_Negotiate_MostSpecificRangeQuality(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	tests := []struct {
+		name         string
+		acceptHeader string
+		priorities   []string
+		expectedType string
+		expectErr    error
+	}{
+		{
+			name:         "subtype beats type wildcard",
+			acceptHeader: "text/*;q=0.5, text/html;q=0.8",
+			priorities:   []string{"text/plain", "text/html"},
+			expectedType: "text/html",
+		},
+		{
+			name:         "wildcard quality applies to unlisted subtype",
+			acceptHeader: "text/*;q=0.5, text/html;q=0.4",
+			priorities:   []string{"text/html", "text/plain"},
+			expectedType: "text/plain",
+		},
+		{
+			name:         "type wildcard beats full wildcard",
+			acceptHeader: "*/*;q=0.9, text/*;q=0.2, application/json;q=0.5",
+			priorities:   []string{"text/html", "application/json"},
+			expectedType: "application/json",
+		},
+		{
+			name:         "full wildcard applies to other types",
+			acceptHeader: "*/*;q=0.9, text/*;q=0.2",
+			priorities:   []string{"text/html", "image/png"},
+			expectedType: "image/png",
+		},
+		{
+			name:         "specific exclusion overrides wildcard",
+			acceptHeader: "text/*, text/html;q=0",
+			priorities:   []string{"text/html", "text/plain"},
+			expectedType: "text/plain",
+		},
+		{
+			name:         "only excluded priority",
+			acceptHeader: "text/*, text/html;q=0",
+			priorities:   []string{"text/html"},
+			expectErr:    ErrNoAcceptableMatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := negotiator.Negotiate(tt.acceptHeader, tt.priorities, false)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+				assert.Nil(t, result)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedType, result.Type)
+		})
+	}
+}
+
+func TestNegotiator_Negotiate_LanguageNormalizedValue(t *testing.T) {
+	negotiator := NewLanguageNegotiator()
+
+	result, err := negotiator.Negotiate("ZH-HANS-CN, en;q=0.5", []string{"en", "zh-hans-cn"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "zh-hans-cn", result.Type)
+	assert.Equal(t, "zh-Hans-CN", result.NormalizedValue)
+
+	// Normalized types keep the canonical casing.
+	negotiator = NewLanguageNegotiator(WithTypeNormalizer(func(typ string) string {
+		if typ == "zh-tw" {
+			return "zh-hant-tw"
+		}
+
+		return typ
+	}))
+
+	elements, err := negotiator.GetOrderedElements("zh-TW")
+	require.NoError(t, err)
+	assert.Equal(t, "zh-Hant-TW", elements[0].String())
+}
+
+func TestNegotiator_Negotiate_SameTypeDifferentParameters(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	tests := []struct {
+		name       string
+		header     string
+		priorities []string
+		expected   string
+	}{
+		{"client charset prefers charset priority", "application/json;charset=utf-8", []string{"application/json", "application/json;charset=utf-8"}, "application/json;charset=utf-8"},
+		{"client charset prefers charset priority listed first", "application/json;charset=utf-8", []string{"application/json;charset=utf-8", "application/json"}, "application/json;charset=utf-8"},
+		{"bare client prefers bare priority", "application/json", []string{"application/json;charset=utf-8", "application/json"}, "application/json"},
+		{"bare client prefers bare priority listed first", "application/json", []string{"application/json", "application/json;charset=utf-8"}, "application/json"},
+		{"fewest unrequested parameters", "text/html;level=1", []string{"text/html;level=1;charset=utf-8", "text/html;level=1"}, "text/html;level=1"},
+		{"other types keep priority order", "application/json, text/html", []string{"text/html;charset=utf-8", "application/json;charset=utf-8", "application/json"}, "text/html;charset=utf-8"},
+		{"higher quality wins over exactness", "application/json;q=0.5, application/json;charset=utf-8", []string{"application/json", "application/json;charset=utf-8"}, "application/json;charset=utf-8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := negotiator.Negotiate(tt.header, tt.priorities, true)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Value)
+		})
+	}
+}
+
+func TestNegotiator_Negotiate_Multipart(t *testing.T) {
+	tests := []struct {
+		name       string
+		negotiator *Negotiator
+		header     string
+		priorities []string
+		expected   string
+		expectErr  error
+	}{
+		{"type wildcard", NewMediaNegotiator(), "multipart/*", []string{"multipart/mixed"}, "multipart/mixed", nil},
+		{"type wildcard with boundary priority", NewMediaNegotiator(), "multipart/*;q=0.5, text/html;q=0.1", []string{"text/html", "multipart/mixed; boundary=AaB03x"}, "multipart/mixed; boundary=AaB03x", nil},
+		{"different boundaries match", NewMediaNegotiator(), "multipart/mixed; boundary=client", []string{"multipart/mixed; boundary=server"}, "multipart/mixed; boundary=server", nil},
+		{"client boundary with exact parameters", NewMediaNegotiator(WithExactParameterMatch(true)), "multipart/mixed; boundary=client", []string{"multipart/mixed"}, "multipart/mixed", nil},
+		{"boundary does not make a priority less exact", NewMediaNegotiator(), "multipart/form-data", []string{"multipart/form-data; boundary=x", "multipart/form-data"}, "multipart/form-data; boundary=x", nil},
+		{"other subtypes do not match", NewMediaNegotiator(), "multipart/mixed", []string{"multipart/form-data"}, "", ErrNoAcceptableMatch},
+		{"other parameters still match", NewMediaNegotiator(), "multipart/mixed; boundary=a; charset=utf-8", []string{"multipart/mixed; boundary=b; charset=iso-8859-1"}, "", ErrNoAcceptableMatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.negotiator.Negotiate(tt.header, tt.priorities, true)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Value)
+		})
+	}
+
+	// The boundary survives parsing of the chosen priority with its case.
+	result, err := NewMediaNegotiator().Negotiate("multipart/*", []string{"multipart/mixed; boundary=AaB03x"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "AaB03x", result.Parameters["boundary"])
+
+	match, err := NewMediaNegotiator().NegotiateMatch("multipart/mixed; boundary=a", []string{"multipart/mixed; boundary=b"}, true)
+	require.NoError(t, err)
+	assert.Zero(t, match.MatchedParameters, "the boundary is not a matched parameter")
+}
+
+func TestNegotiator_Negotiate_WildcardExclusion(t *testing.T) {
+	tests := []struct {
+		name       string
+		negotiator *Negotiator
+		header     string
+		priorities []string
+		strict     bool
+		expected   string
+		expectErr  error
+	}{
+		{"listed type is chosen", NewMediaNegotiator(), "application/json, */*;q=0", []string{"application/json"}, false, "application/json", nil},
+		{"unlisted type is excluded", NewMediaNegotiator(), "application/json, */*;q=0", []string{"text/html"}, false, "", ErrNoAcceptableMatch},
+		{"listed type among excluded", NewMediaNegotiator(), "application/json, */*;q=0", []string{"text/html", "application/json"}, false, "application/json", nil},
+		{"exclusion listed first", NewMediaNegotiator(), "*/*;q=0, application/json", []string{"text/html", "application/json"}, false, "application/json", nil},
+		{"type wildcard exclusion", NewMediaNegotiator(), "text/html, text/*;q=0, */*;q=0.1", []string{"text/plain", "image/png"}, false, "image/png", nil},
+		{"strict mode", NewMediaNegotiator(), "application/json, */*;q=0", []string{"text/html"}, true, "", ErrNoAcceptableMatch},
+		{"language", NewLanguageNegotiator(), "en, *;q=0", []string{"fr"}, false, "", ErrNoAcceptableMatch},
+		{"charset", NewCharsetNegotiator(), "utf-8, *;q=0", []string{"iso-8859-1", "utf-8"}, false, "utf-8", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.negotiator.Negotiate(tt.header, tt.priorities, tt.strict)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+				assert.Nil(t, result)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Value)
+		})
+	}
+}
+
+func TestNegotiator_Negotiate_RFC7231LevelExample(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	// Worked example from RFC 7231 section 5.3.2.
+	header := "text/*;q=0.3, text/html;q=0.7, text/html;level=1, text/html;level=2;q=0.4, */*;q=0.5"
+	expected := []struct {
+		priority string
+		quality  float64
+	}{
+		{"text/html;level=1", 1},
+		{"text/html", 0.7},
+		{"text/plain", 0.3},
+		{"image/jpeg", 0.5},
+		{"text/html;level=2", 0.4},
+		{"text/html;level=3", 0.7},
+	}
+
+	priorities := make([]string, len(expected))
+	for i, e := range expected {
+		priorities[i] = e.priority
+	}
+
+	result, trace, err := negotiator.NegotiateWithTrace(header, priorities, true)
+	require.NoError(t, err)
+	assert.Equal(t, "text/html;level=1", result.Value)
+
+	require.Len(t, trace.Priorities, len(expected))
+	for i, e := range expected {
+		assert.InDelta(t, e.quality, trace.Priorities[i].Quality, 0.0001, e.priority)
+	}
+}
+
+func TestNegotiator_Negotiate_LevelParameterSpecificity(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	// The level-matching range applies to text/html;level=1 regardless of header order.
+	result, err := negotiator.Negotiate("text/html;q=0.9, text/html;level=1;q=0.2", []string{"text/html;level=1", "text/html"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "text/html", result.Value)
+
+	result, err = negotiator.Negotiate("text/html;q=0.2, text/html;level=1", []string{"text/html", "text/html;level=1"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "text/html;level=1", result.Value)
+}
+
+func TestNegotiator_FindMatches_ResolvedQuality(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	headers, err := negotiator.parseAcceptHeaders("text/*;q=0.5, */*;q=0.1, text/html;q=0.8", true)
+	require.NoError(t, err)
+
+	priorities := make([]*Header, 0, 3)
+	for _, p := range []string{"text/html", "text/plain", "image/png"} {
+		h, err := newMedia(p)
+		require.NoError(t, err)
+		priorities = append(priorities, h)
+	}
+
+	matches := negotiator.reduceMatches(negotiator.findMatches(headers, priorities))
+	qualities := make(map[int]float64, len(matches))
+	for _, m := range matches {
+		qualities[m.Index] = m.Quality
+	}
+
+	assert.Equal(t, map[int]float64{0: 0.8, 1: 0.5, 2: 0.1}, qualities)
+}
+
+func TestNegotiator_Negotiate_IgnoresPriorityQuality(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	result, err := negotiator.Negotiate("application/json", []string{"application/json;q=0.1"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", result.Type)
+	assert.Equal(t, 1.0, result.Quality)
+	assert.Empty(t, result.Parameters)
+
+	// A low q on a priority does not demote it below other priorities.
+	result, err = negotiator.Negotiate("text/html, application/json", []string{"application/json;q=0.1", "text/html"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", result.Type)
+}
+
+func TestNegotiator_Acceptable(t *testing.T) {
+	tests := []struct {
+		name         string
+		negotiator   *Negotiator
+		acceptHeader string
+		candidate    string
+		strict       bool
+		expected     bool
+		expectErr    error
+	}{
+		{"explicit media type", NewMediaNegotiator(), "text/html, application/json", "application/json", false, true, nil},
+		{"media type via wildcard", NewMediaNegotiator(), "text/*", "text/plain", false, true, nil},
+		{"media type not listed", NewMediaNegotiator(), "text/html", "application/json", false, false, nil},
+		{"media type rejected with q=0", NewMediaNegotiator(), "*/*, application/xml;q=0", "application/xml", false, false, nil},
+		{"encoding explicitly accepted", NewEncodingNegotiator(), "br, gzip;q=0.8", "br", false, true, nil},
+		{"encoding not accepted", NewEncodingNegotiator(), "gzip", "br", false, false, nil},
+		{"invalid candidate non-strict", NewMediaNegotiator(), "text/html", "invalid", false, false, nil},
+		{"invalid candidate strict", NewMediaNegotiator(), "text/html", "invalid", true, false, &InvalidMediaTypeError{}},
+		{"full wildcard candidate", NewMediaNegotiator(), "text/html", "*/*", false, false, nil},
+		{"full wildcard candidate under full wildcard", NewMediaNegotiator(), "*/*", "*/*", false, true, nil},
+		{"type wildcard candidate", NewMediaNegotiator(), "application/json-patch+json", "application/*", false, false, nil},
+		{"empty header", NewMediaNegotiator(), "", "text/html", false, false, ErrEmptyHeader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := tt.negotiator.Acceptable(tt.acceptHeader, tt.candidate, tt.strict)
+			if tt.expectErr != nil {
+				require.Error(t, err)
+				assert.IsType(t, tt.expectErr, err)
+				assert.False(t, ok)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, ok)
+		})
+	}
+}
+
+func TestNegotiator_QualityOf(t *testing.T) {
+	ladder := "text/*;q=0.3, text/plain;q=0.7, text/plain;format=flowed, */*;q=0.5"
+
+	tests := []struct {
+		name         string
+		negotiator   *Negotiator
+		acceptHeader string
+		candidate    string
+		strict       bool
+		expected     float64
+		expectErr    error
+	}{
+		{"most specific range wins", NewMediaNegotiator(), ladder, "text/plain;format=flowed", true, 1, nil},
+		{"type over type wildcard", NewMediaNegotiator(), ladder, "text/plain", true, 0.7, nil},
+		{"type wildcard over full wildcard", NewMediaNegotiator(), ladder, "text/html", true, 0.3, nil},
+		{"full wildcard", NewMediaNegotiator(), ladder, "image/jpeg", true, 0.5, nil},
+		{"unrequested parameter ignored", NewMediaNegotiator(), ladder, "text/plain;format=fixed", true, 0.7, nil},
+		{"specific range rejects", NewMediaNegotiator(), "*/*, application/xml;q=0", "application/xml", true, 0, nil},
+		{"not matched", NewMediaNegotiator(), "text/html", "application/json", true, 0, nil},
+		{"full wildcard candidate", NewMediaNegotiator(), "text/html;q=0.4", "*/*", true, 0, nil},
+		{"language prefix", NewLanguageNegotiator(), "en;q=0.6, en-GB;q=0.9", "en-US", true, 0.6, nil},
+		{"encoding", NewEncodingNegotiator(), "gzip;q=0.4, *;q=0.1", "gzip", true, 0.4, nil},
+		{"invalid candidate non-strict", NewMediaNegotiator(), "text/html", "invalid", false, 0, nil},
+		{"invalid candidate strict", NewMediaNegotiator(), "text/html", "invalid", true, 0, &InvalidMediaTypeError{}},
+		{"malformed header strict", NewMediaNegotiator(), "text/html;q=high", "text/html", true, 0, &InvalidQualityError{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := tt.negotiator.QualityOf(tt.acceptHeader, tt.candidate, tt.strict)
+			if tt.expectErr != nil {
+				require.Error(t, err)
+				assert.IsType(t, tt.expectErr, err)
+				assert.Zero(t, q)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.InDelta(t, tt.expected, q, 1e-9)
+		})
+	}
+}
+
+func TestNegotiator_StillAcceptable(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+	chosen := "text/html; charset=utf-8"
+
+	tests := []struct {
+		name         string
+		acceptHeader string
+		expected     bool
+	}{
+		{"same header", "text/html, application/json;q=0.9", true},
+		{"now less preferred", "application/json, text/html;q=0.1", true},
+		{"via wildcard", "application/json, */*;q=0.1", true},
+		{"matching charset", "text/html;charset=UTF-8", true},
+		{"other charset", "text/html;charset=iso-8859-1", false},
+		{"excluded", "*/*, text/html;q=0", false},
+		{"not listed", "application/json", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := negotiator.StillAcceptable(tt.acceptHeader, chosen, true)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, ok)
+		})
+	}
+
+	_, err := negotiator.StillAcceptable("text/html", "html", true)
+	assert.IsType(t, &InvalidMediaTypeError{}, err)
+}
+
+func TestNegotiator_ValidatePriorities(t *testing.T) {
+	tests := []struct {
+		name       string
+		negotiator *Negotiator
+		priorities []string
+		expected   []string
+	}{
+		{"valid media types", NewMediaNegotiator(), []string{"application/json", "text/html; charset=utf-8"}, nil},
+		{"valid languages", NewLanguageNegotiator(), []string{"en-US", "zh-Hans-CN"}, nil},
+		{"double slash", NewMediaNegotiator(), []string{"application/json", "application//json"}, []string{`priority 1 "application//json"`}},
+		{
+			"all invalid entries",
+			NewMediaNegotiator(),
+			[]string{"json", "text/html", "text/html;q=abc"},
+			[]string{`priority 0 "json"`, `priority 2 "text/html;q=abc"`},
+		},
+		{"invalid language", NewLanguageNegotiator(), []string{"en_US"}, []string{`priority 0 "en_US"`}},
+		{
+			"with negotiator options",
+			NewMediaNegotiator(WithTypeNormalizer(func(string) string { return "broken" })),
+			[]string{"text/html"},
+			[]string{`priority 0 "text/html"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.negotiator.ValidatePriorities(tt.priorities)
+			if tt.expected == nil {
+				require.NoError(t, err)
+
+				return
+			}
+
+			require.Error(t, err)
+			for _, msg := range tt.expected {
+				assert.Contains(t, err.Error(), msg)
+			}
+			var joined interface{ Unwrap() []error }
+			require.ErrorAs(t, err, &joined)
+			assert.Len(t, joined.Unwrap(), len(tt.expected))
+		})
+	}
+
+	err := NewMediaNegotiator().ValidatePriorities([]string{"text"})
+	var mediaErr *InvalidMediaTypeError
+	require.ErrorAs(t, err, &mediaErr)
+
+	require.ErrorIs(t, NewMediaNegotiator().ValidatePriorities(nil), ErrEmptyPriorities)
+}
+
+func TestNegotiator_Rejected(t *testing.T) {
+	tests := []struct {
+		name         string
+		negotiator   *Negotiator
+		acceptHeader string
+		priorities   []string
+		expected     []string
+		expectErr    error
+	}{
+		{"excluded with q=0", NewMediaNegotiator(), "image/*, image/webp;q=0", []string{"image/webp", "image/png"}, []string{"image/webp"}, nil},
+		{"not mentioned", NewMediaNegotiator(), "text/html", []string{"application/json", "text/html"}, []string{"application/json"}, nil},
+		{"narrowed by type range", NewMediaNegotiator(), "image/*", []string{"image/avif", "text/html", "image/png"}, []string{"text/html"}, nil},
+		{"all accepted", NewEncodingNegotiator(), "gzip, br", []string{"br", "gzip"}, []string{}, nil},
+		{"invalid priority skipped", NewMediaNegotiator(), "text/html", []string{"invalid", "application/json"}, []string{"application/json"}, nil},
+		{"full wildcard resolved to a client type", NewMediaNegotiator(), "text/html", []string{"*/*", "application/json"}, []string{"application/json"}, nil},
+		{"full wildcard without client types", NewMediaNegotiator(), "text/html;q=0", []string{"*/*"}, []string{"*/*"}, nil},
+		{"type wildcard resolved to a client type", NewMediaNegotiator(), "application/cbor", []string{"text/html", "application/*"}, []string{"text/html"}, nil},
+		{"empty header", NewMediaNegotiator(), "", []string{"text/html"}, nil, ErrEmptyHeader},
+		{"empty priorities", NewMediaNegotiator(), "text/html", nil, nil, ErrEmptyPriorities},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rejected, err := tt.negotiator.Rejected(tt.acceptHeader, tt.priorities)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+				assert.Nil(t, rejected)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, rejected)
+		})
+	}
+}
+
+func TestNegotiator_Negotiate_PreservesParameterValueCase(t *testing.T) {
+	priority := "multipart/form-data; boundary=AaBb"
+
+	for _, negotiator := range []*Negotiator{
+		NewMediaNegotiator(),
+		NewMediaNegotiator(WithRFC7231Strict()),
+		NewMediaNegotiator(WithCharsetParamMatching(true)),
+		NewMediaNegotiator(WithTypeNormalizer(strings.ToLower)),
+	} {
+		result, err := negotiator.Negotiate("multipart/*", []string{priority}, true)
+		require.NoError(t, err)
+		assert.Equal(t, "multipart/form-data", result.Type)
+		assert.Equal(t, map[string]string{"boundary": "AaBb"}, result.Parameters)
+		assert.Equal(t, priority, result.NormalizedValue)
+	}
+
+	elements, err := NewMediaNegotiator().GetOrderedElements("Text/HTML; Charset=UTF-8; Title=\"Mixed Case\"")
+	require.NoError(t, err)
+	require.Len(t, elements, 1)
+	assert.Equal(t, map[string]string{"charset": "UTF-8", "title": "Mixed Case"}, elements[0].Parameters)
+	assert.Equal(t, `text/html; charset=UTF-8; title="Mixed Case"`, elements[0].NormalizedValue)
+}
+
+func TestNegotiator_Normalize_RoundTrip(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	headers := []string{
+		`text/html; title="a b", application/json;q=0.5`,
+		`text/plain; foo="x,y";q=0.9, text/*; bar="a\"b"`,
+		"text/html;level=1;q=0.7, */*;q=0.1",
+	}
+
+	for _, header := range headers {
+		t.Run(header, func(t *testing.T) {
+			normalized, err := negotiator.Normalize(header)
+			require.NoError(t, err)
+
+			again, err := negotiator.Normalize(normalized)
+			require.NoError(t, err)
+			assert.Equal(t, normalized, again)
+
+			original, err := negotiator.GetOrderedElements(header)
+			require.NoError(t, err)
+			reparsed, err := negotiator.GetOrderedElements(normalized)
+			require.NoError(t, err)
+			require.Len(t, reparsed, len(original))
+			for i := range original {
+				assert.Equal(t, original[i].Type, reparsed[i].Type)
+				assert.Equal(t, original[i].Parameters, reparsed[i].Parameters)
+				assert.Equal(t, original[i].Quality, reparsed[i].Quality)
+			}
+		})
+	}
+}
+
+func TestNegotiator_MalformedQuality(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	tests := []struct {
+		q        string
+		lenient  float64
+		explicit bool
+	}{
+		// Values that are not plain decimals are ignored in lenient mode.
+		{"0.5x", 1.0, false},
+		{"", 1.0, false},
+		{"abc", 1.0, false},
+		{"0.5abc", 1.0, false},
+		{"NaN", 1.0, false},
+		{"inf", 1.0, false},
+		{"0x1p-1", 1.0, false},
+		{"1e-1", 1.0, false},
+		// Plain decimals outside the qvalue grammar are clamped in lenient mode.
+		{"+.5", 0.5, true},
+		{"0.12345", 0.123, true},
+		{"0.0001", 0.0, true},
+		{"1.5", 1.0, true},
+		{"-0.5", 0.0, true},
+		{"-1", 0.0, true},
+	}
+
+	for _, tt := range tests {
+		header := "text/html;level=1;q=" + tt.q
+
+		t.Run(header, func(t *testing.T) {
+			// Strict mode pinpoints the malformed value.
+			_, err := negotiator.Negotiate(header+", application/json", []string{"text/html"}, true)
+			var qualityErr *InvalidQualityError
+			require.ErrorAs(t, err, &qualityErr)
+			assert.Equal(t, tt.q, qualityErr.Quality)
+			assert.Equal(t, header, qualityErr.Header)
+
+			// Lenient mode clamps a plain decimal and otherwise uses the default quality.
+			elements, skipped := negotiator.GetOrderedElementsLenient("application/json;q=0.9, " + header)
+			require.Len(t, skipped, 1)
+			require.Len(t, elements, 2)
+			e := elements[0]
+			if e.Type != "text/html" {
+				e = elements[1]
+			}
+			assert.Equal(t, tt.lenient, e.Quality)
+			assert.Equal(t, tt.explicit, e.QualityExplicit)
+			assert.Equal(t, map[string]string{"level": "1"}, e.Parameters)
+			assert.Equal(t, header, e.Value)
+		})
+	}
+}
+
+func TestNegotiator_MalformedQuality_LenientKeepsClientIntent(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	result, err := NewEncodingNegotiator().Negotiate("gzip;q=-1, br;q=0.5", []string{"gzip", "br"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "br", result.Value)
+
+	result, err = negotiator.Negotiate("text/html;q=0.0001, application/json;q=0.5", []string{"text/html", "application/json"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", result.Value)
+}
+
+func TestNegotiator_GetOrderedElements_Offsets(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+	header := ` text/html;q=0.5 ,application/json; foo="a,b" ,, */*;q=0.1 `
+
+	elements, err := negotiator.GetOrderedElements(header)
+	require.NoError(t, err)
+	require.Len(t, elements, 3)
+
+	expected := []string{`application/json; foo="a,b"`, "text/html;q=0.5", "*/*;q=0.1"}
+	for i, e := range elements {
+		assert.Equal(t, expected[i], header[e.Start:e.End])
+		assert.Equal(t, e.Value, header[e.Start:e.End])
+	}
+	assert.Equal(t, 1, elements[1].Start)
+
+	// Priorities carry no offsets.
+	result, err := negotiator.Negotiate(header, []string{"application/json; foo=\"a,b\""}, false)
+	require.NoError(t, err)
+	assert.Zero(t, result.Start)
+	assert.Zero(t, result.End)
+}
+
+func TestNegotiator_EmptyParameters(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	tests := []struct {
+		name   string
+		header string
+		params map[string]string
+	}{
+		{"doubled separator", "text/html;;level=1", map[string]string{"level": "1"}},
+		{"spaced doubled separator", "text/html; ; level=1 ;q=0.5", map[string]string{"level": "1"}},
+		{"trailing separator", "text/html;level=1;", map[string]string{"level": "1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Lenient mode skips the empty parameter.
+			elements, err := negotiator.GetOrderedElements(tt.header)
+			require.NoError(t, err)
+			require.Len(t, elements, 1)
+			assert.Equal(t, "text/html", elements[0].Type)
+			assert.Equal(t, tt.params, elements[0].Parameters)
+
+			result, err := negotiator.Negotiate(tt.header, []string{"text/html;level=1"}, false)
+			require.NoError(t, err)
+			assert.Equal(t, "text/html;level=1", result.Value)
+
+			// Strict mode rejects it.
+			_, err = negotiator.Negotiate(tt.header, []string{"text/html;level=1"}, true)
+			assert.IsType(t, &InvalidHeaderError{}, err)
+		})
+	}
+}
+
+func TestNegotiator_MissingMediaType(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	for _, header := range []string{";foo=bar", ";q=0.5", " ; level=1"} {
+		t.Run(header, func(t *testing.T) {
+			_, err := negotiator.Negotiate(header, []string{"text/html"}, true)
+			assert.IsType(t, &InvalidMediaTypeError{}, err)
+
+			// Lenient mode skips the element.
+			elements, err := negotiator.GetOrderedElements(header + ", text/html")
+			require.NoError(t, err)
+			require.Len(t, elements, 1)
+			assert.Equal(t, "text/html", elements[0].Type)
+		})
+	}
+}
+
+func TestNegotiator_GetOrderedElementsLenient(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+	header := "invalid/header/format, text/html;q=0.5, application/json;q=abc, bogus"
+
+	elements, skipped := negotiator.GetOrderedElementsLenient(header)
+
+	// Same elements as GetOrderedElements.
+	ordered, err := negotiator.GetOrderedElements(header)
+	require.NoError(t, err)
+	assert.Equal(t, ordered, elements)
+	require.Len(t, elements, 2)
+	assert.Equal(t, "application/json", elements[0].Type)
+	assert.Equal(t, "text/html", elements[1].Type)
+
+	require.Len(t, skipped, 3)
+	assert.EqualError(t, skipped[0], `element 0 "invalid/header/format": invalid media type`)
+	assert.IsType(t, &InvalidMediaTypeError{}, errors.Unwrap(skipped[0]))
+
+	var qualityErr *InvalidQualityError
+	require.ErrorAs(t, skipped[1], &qualityErr)
+	assert.Equal(t, "abc", qualityErr.Quality)
+	assert.Contains(t, skipped[1].Error(), `element 2 "application/json;q=abc"`)
+
+	assert.Contains(t, skipped[2].Error(), `element 3 "bogus"`)
+}
+
+func TestNegotiator_GetOrderedElementsLenient_NoRecovery(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	elements, skipped := negotiator.GetOrderedElementsLenient("text/html, application/json;q=0.9")
+	assert.Len(t, elements, 2)
+	assert.Empty(t, skipped)
+
+	elements, skipped = negotiator.GetOrderedElementsLenient("")
+	assert.Empty(t, elements)
+	require.Len(t, skipped, 1)
+	require.ErrorIs(t, skipped[0], ErrEmptyHeader)
+
+	elements, skipped = negotiator.GetOrderedElementsLenient(" , ")
+	assert.Empty(t, elements)
+	require.Len(t, skipped, 1)
+	assert.IsType(t, &InvalidHeaderError{}, skipped[0])
+}
+
+func TestNegotiator_GetOrderedElementsWithStats(t *testing.T) {
+	tests := []struct {
+		name       string
+		negotiator *Negotiator
+		header     string
+		expected   ElementStats
+	}{
+		{"concrete types", NewMediaNegotiator(), "text/html, application/json;q=0.9", ElementStats{}},
+		{"type wildcard", NewMediaNegotiator(), "text/html, image/*;q=0.5", ElementStats{HasWildcard: true}},
+		{"suffix wildcard", NewMediaNegotiator(), "application/*+json", ElementStats{HasWildcard: true}},
+		{"full wildcard", NewMediaNegotiator(), "text/html, */*;q=0.1", ElementStats{HasWildcard: true, HasFullWildcard: true}},
+		{"rejected wildcard", NewMediaNegotiator(), "text/html, */*;q=0", ElementStats{HasWildcard: true, HasFullWildcard: true}},
+		{"language wildcard", NewLanguageNegotiator(), "en, *;q=0.5", ElementStats{HasWildcard: true, HasFullWildcard: true}},
+		{"encoding without wildcard", NewEncodingNegotiator(), "gzip, br", ElementStats{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			elements, stats, err := tt.negotiator.GetOrderedElementsWithStats(tt.header)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, stats)
+
+			ordered, err := tt.negotiator.GetOrderedElements(tt.header)
+			require.NoError(t, err)
+			assert.Equal(t, ordered, elements)
+		})
+	}
+
+	_, stats, err := NewMediaNegotiator().GetOrderedElementsWithStats("")
+	require.ErrorIs(t, err, ErrEmptyHeader)
+	assert.Equal(t, ElementStats{}, stats)
+}
+
+func TestNegotiator_Normalize(t *testing.T) {
+	tests := []struct {
+		name       string
+		negotiator *Negotiator
+		header     string
+		expected   string
+		expectErr  bool
+	}{
+		{"single type", NewMediaNegotiator(), "text/html", "text/html", false},
+		{"drops q=1", NewMediaNegotiator(), "text/html;q=1.0", "text/html", false},
+		{"orders by quality", NewMediaNegotiator(), "text/plain;q=0.5, TEXT/HTML", "text/html, text/plain; q=0.5", false},
+		{"sorts parameters", NewMediaNegotiator(), "text/html; z=y; a=b;q=0.8", "text/html; a=b; z=y; q=0.8", false},
+		{"keeps stable order on ties", NewMediaNegotiator(), "b/b;q=0.5, a/a;q=0.5", "b/b; q=0.5, a/a; q=0.5", false},
+		{"keeps rejections", NewMediaNegotiator(), "*/*, application/xml;q=0", "*/*, application/xml; q=0", false},
+		{"language", NewLanguageNegotiator(), "EN-us;q=0.7, fr", "fr, en-US; q=0.7", false},
+		{"empty header", NewMediaNegotiator(), "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.negotiator.Normalize(tt.header)
+			if tt.expectErr {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestNegotiator_Normalize_Equivalent(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	a, err := negotiator.Normalize("text/html;level=1;charset=utf-8, application/json;q=0.9")
+	require.NoError(t, err)
+	b, err := negotiator.Normalize("application/json ; Q=0.90,TEXT/html; charset=utf-8; level=1; q=1")
+	require.NoError(t, err)
+
+	assert.Equal(t, a, b)
+}
+
+func TestNegotiator_Negotiate_LanguageScript(t *testing.T) {
+	negotiator := NewLanguageNegotiator()
+
+	tests := []struct {
+		name         string
+		acceptHeader string
+		priorities   []string
+		expectedType string
+		expectErr    error
+	}{
+		{"simplified chinese", "zh-Hans-CN", []string{"zh-Hant-TW", "zh-Hans-CN"}, "zh-hans-cn", nil},
+		{"traditional chinese", "zh-Hant", []string{"zh-Hans-CN", "zh-Hant-TW"}, "zh-hant-tw", nil},
+		{"script-less priority", "zh-Hant", []string{"zh"}, "zh", nil},
+		{"script mismatch", "zh-Hant-CN", []string{"zh-Hans-CN"}, "", ErrNoAcceptableMatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := negotiator.Negotiate(tt.acceptHeader, tt.priorities, false)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedType, result.Type)
+		})
+	}
+}
+
+func TestNegotiator_Negotiate_ControlCharacters(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	// Strict mode rejects header injection attempts.
+	_, err := negotiator.Negotiate("text/html\r\nX-Injected: 1", []string{"text/html"}, true)
+	require.Error(t, err)
+	assert.IsType(t, &InvalidHeaderError{}, err)
+
+	_, err = negotiator.Negotiate("text/html,\n application/json", []string{"text/html"}, true)
+	require.Error(t, err)
+
+	// Optional whitespace is still accepted in strict mode.
+	result, err := negotiator.Negotiate("text/html\t;\tlevel = 1", []string{"text/html;level=1"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "text/html", result.Type)
+}
+
+func TestNegotiator_Negotiate_ParamValueWhitespace(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	tests := []struct {
+		name     string
+		header   string
+		priority string
+	}{
+		{"spaced header", "text/html; level = 2", "text/html;level=2"},
+		{"spaced priority", "text/html;level=2", "text/html; level = 2 "},
+		{"trailing space before q", "text/html;level=2 ;q=0.5", "text/html;level=2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := negotiator.Negotiate(tt.header, []string{tt.priority}, true)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{"level": "2"}, result.Parameters)
+			assert.Equal(t, "text/html; level=2", result.NormalizedValue)
+		})
+	}
+
+	_, err := negotiator.Negotiate("text/html; level = 2", []string{"text/html;level=3"}, true)
+	require.ErrorIs(t, err, ErrNoAcceptableMatch)
+}
+
+func TestNegotiator_Negotiate_TE(t *testing.T) {
+	negotiator := NewTENegotiator()
+
+	// Trailers support is a presence check.
+	result, err := negotiator.Negotiate("trailers;q=0.2, deflate;q=0.5", []string{"trailers"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "trailers", result.Type)
+
+	_, err = negotiator.Negotiate("deflate", []string{"trailers"}, false)
+	require.ErrorIs(t, err, ErrNoAcceptableMatch)
+
+	// Transfer codings are ranked by quality.
+	result, err = negotiator.Negotiate("trailers, deflate;q=0.5, gzip;q=0.8", []string{"deflate", "gzip"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "gzip", result.Type)
+}
+
+func TestNegotiator_SetMaxParameters(t *testing.T) {
+	// params returns a media range with n parameters
+	params := func(typ string, n int) string {
+		var b strings.Builder
+		b.WriteString(typ)
+		for i := range n {
+			fmt.Fprintf(&b, ";p%d=%d", i, i)
+		}
+
+		return b.String()
+	}
+
+	t.Run("default limit", func(t *testing.T) {
+		negotiator := NewMediaNegotiator()
+
+		result, err := negotiator.Negotiate(params("text/html", DefaultMaxParameters), []string{"text/html"}, true)
+		require.NoError(t, err)
+		assert.Equal(t, "text/html", result.Type)
+
+		header := params("text/html", DefaultMaxParameters+1)
+		_, err = negotiator.Negotiate(header, []string{"text/html"}, true)
+		require.ErrorIs(t, err, ErrMalformedHeader)
+		var tooMany *TooManyParametersError
+		require.ErrorAs(t, err, &tooMany)
+		assert.Equal(t, header, tooMany.Header)
+		assert.Equal(t, DefaultMaxParameters, tooMany.Max)
+	})
+
+	t.Run("lenient mode skips the element", func(t *testing.T) {
+		negotiator := NewMediaNegotiator()
+		negotiator.SetMaxParameters(2)
+
+		result, err := negotiator.Negotiate("text/html;a=1;b=2;c=3, application/json;q=0.5", []string{"text/html", "application/json"}, false)
+		require.NoError(t, err)
+		assert.Equal(t, "application/json", result.Type)
+	})
+
+	t.Run("accept-ext counts but q does not", func(t *testing.T) {
+		negotiator := NewMediaNegotiator(WithRFC7231Strict())
+		negotiator.SetMaxParameters(2)
+
+		_, err := negotiator.Negotiate("text/html;a=1;q=0.5;b=2", []string{"text/html"}, true)
+		require.NoError(t, err)
+
+		_, err = negotiator.Negotiate("text/html;a=1;q=0.5;b=2;c=3", []string{"text/html"}, true)
+		assert.IsType(t, &TooManyParametersError{}, err)
+	})
+
+	t.Run("zero removes the limit", func(t *testing.T) {
+		negotiator := NewMediaNegotiator()
+		negotiator.SetMaxParameters(0)
+
+		result, err := negotiator.Negotiate(params("text/html", 1000), []string{"text/html"}, true)
+		require.NoError(t, err)
+		assert.Equal(t, "text/html", result.Type)
+	})
+
+	t.Run("priorities are not limited", func(t *testing.T) {
+		negotiator := NewMediaNegotiator()
+		negotiator.SetMaxParameters(1)
+
+		result, err := negotiator.Negotiate("text/html", []string{"text/html;a=1;b=2"}, true)
+		require.NoError(t, err)
+		assert.Equal(t, "text/html", result.Type)
+	})
+}
+
+func TestNegotiator_SetComparator(t *testing.T) {
+	// Prefer application/* over text/*, then fall back to the default ordering.
+	preferApplication := func(a, b *Header) int {
+		aApp, bApp := a.BasePart == "application", b.BasePart == "application"
+		if aApp != bApp {
+			if aApp {
+				return -1
+			}
+
+			return 1
+		}
+
+		return DefaultComparator(a, b)
+	}
+
+	t.Run("GetOrderedElements", func(t *testing.T) {
+		negotiator := NewMediaNegotiator()
+		negotiator.SetComparator(preferApplication)
+
+		elements, err := negotiator.GetOrderedElements("text/html, application/xml;q=0.5, text/plain;q=0.9, application/json;q=0.5")
+		require.NoError(t, err)
+		require.Len(t, elements, 4)
+		assert.Equal(t, "application/xml", elements[0].Type)
+		assert.Equal(t, "application/json", elements[1].Type)
+		assert.Equal(t, "text/html", elements[2].Type)
+		assert.Equal(t, "text/plain", elements[3].Type)
+	})
+
+	t.Run("Negotiate", func(t *testing.T) {
+		negotiator := NewMediaNegotiator()
+		negotiator.SetComparator(preferApplication)
+
+		result, err := negotiator.Negotiate("text/html, application/json;q=0.5", []string{"text/html", "application/json"}, false)
+		require.NoError(t, err)
+		assert.Equal(t, "application/json", result.Type)
+	})
+
+	t.Run("ties keep original order", func(t *testing.T) {
+		negotiator := NewMediaNegotiator()
+		negotiator.SetComparator(func(_, _ *Header) int { return 0 })
+
+		elements, err := negotiator.GetOrderedElements("a/a;q=0.1, b/b, c/c;q=0.5")
+		require.NoError(t, err)
+		assert.Equal(t, "a/a", elements[0].Type)
+		assert.Equal(t, "b/b", elements[1].Type)
+		assert.Equal(t, "c/c", elements[2].Type)
+
+		result, err := negotiator.Negotiate("*/*;q=0.1, c/c", []string{"a/a", "b/b", "c/c"}, false)
+		require.NoError(t, err)
+		assert.Equal(t, "a/a", result.Type)
+	})
+
+	t.Run("nil restores default", func(t *testing.T) {
+		negotiator := NewMediaNegotiator()
+		negotiator.SetComparator(preferApplication)
+		negotiator.SetComparator(nil)
+
+		result, err := negotiator.Negotiate("text/html, application/json;q=0.5", []string{"text/html", "application/json"}, false)
+		require.NoError(t, err)
+		assert.Equal(t, "text/html", result.Type)
+	})
+}
+
+func TestNegotiator_Negotiate_MatchedVia(t *testing.T) {
+	tests := []struct {
+		name         string
+		negotiator   *Negotiator
+		acceptHeader string
+		priorities   []string
+		expected     MatchKind
+	}{
+		{"exact media type", NewMediaNegotiator(), "application/json", []string{"application/json"}, MatchExact},
+		{"full media wildcard", NewMediaNegotiator(), "*/*", []string{"application/json"}, MatchFullWildcard},
+		{"single media wildcard", NewMediaNegotiator(), "*", []string{"application/json"}, MatchFullWildcard},
+		{"type wildcard", NewMediaNegotiator(), "text/*", []string{"text/html"}, MatchTypeWildcard},
+		{"suffix wildcard", NewMediaNegotiator(), "application/*+json", []string{"application/vnd.api+json"}, MatchSuffix},
+		{"most specific range wins", NewMediaNegotiator(), "*/*, application/json", []string{"application/json"}, MatchExact},
+		{"language wildcard", NewLanguageNegotiator(), "*", []string{"en"}, MatchFullWildcard},
+		{"exact language", NewLanguageNegotiator(), "en-US", []string{"en-US"}, MatchExact},
+		{"encoding wildcard", NewEncodingNegotiator(), "*", []string{"gzip"}, MatchFullWildcard},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.negotiator.Negotiate(tt.acceptHeader, tt.priorities, false)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.MatchedVia)
+		})
+	}
+}
+
+func TestDedupElements(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	elements, err := negotiator.parseAcceptHeaders("text/html, text/html;q=0.9, application/json;q=0.5, application/json;q=0.8, application/json;q=0.8", false)
+	require.NoError(t, err)
+	require.Len(t, elements, 2)
+
+	// The first of two equal qualities is kept.
+	assert.Equal(t, "text/html", elements[0].Type)
+	assert.Equal(t, 1.0, elements[0].Quality)
+	assert.Equal(t, 0, elements[0].originalIndex)
+
+	assert.Equal(t, "application/json", elements[1].Type)
+	assert.Equal(t, 0.8, elements[1].Quality)
+	assert.Equal(t, 3, elements[1].originalIndex)
+
+	// Negotiate resolves the collapsed quality as well.
+	result, err := negotiator.Negotiate("text/html;q=0.5, application/json;q=0.9, text/html", []string{"application/json", "text/html"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "text/html", result.Type)
+}
+
+func TestNegotiator_Negotiate_EncodingWildcard(t *testing.T) {
+	negotiator := NewEncodingNegotiator()
+
+	tests := []struct {
+		name         string
+		acceptHeader string
+		priorities   []string
+		expectedType string
+		expectErr    error
+	}{
+		{"wildcard returns first priority", "*", []string{"br", "gzip"}, "br", nil},
+		{"wildcard matches custom codings", "*", []string{"zstd"}, "zstd", nil},
+		{"explicit coding beats wildcard quality", "*;q=0.5, gzip", []string{"br", "gzip"}, "gzip", nil},
+		{"wildcard fills unlisted codings", "gzip;q=0.2, *;q=0.5", []string{"gzip", "br"}, "br", nil},
+		{"wildcard rejection keeps listed codings", "br;q=0.8, *;q=0", []string{"gzip", "zstd", "br"}, "br", nil},
+		{"wildcard rejection excludes everything else", "gzip, *;q=0", []string{"br", "zstd"}, "", ErrNoAcceptableMatch},
+		{"explicit rejection overrides wildcard", "*, gzip;q=0", []string{"gzip", "br"}, "br", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := negotiator.Negotiate(tt.acceptHeader, tt.priorities, false)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedType, result.Type)
+		})
+	}
+}
+
+func TestNegotiator_QualityParamCaseAndSpacing(t *testing.T) {
+	negotiators := []struct {
+		name       string
+		negotiator *Negotiator
+		preferred  string
+		other      string
+	}{
+		{"media", NewMediaNegotiator(), "application/json", "text/html"},
+		{"language", NewLanguageNegotiator(), "de", "en"},
+		{"charset", NewCharsetNegotiator(), "iso-8859-1", "utf-8"},
+		{"encoding", NewEncodingNegotiator(), "br", "gzip"},
+	}
+	spellings := []string{";q=0.5", "; Q=0.5", ";q = 0.5", " ; Q = 0.5", ";\tQ\t=0.5"}
+
+	for _, n := range negotiators {
+		for _, spelling := range spellings {
+			t.Run(n.name+" "+spelling, func(t *testing.T) {
+				header := n.other + spelling + ", " + n.preferred
+
+				for _, strict := range []bool{true, false} {
+					result, err := n.negotiator.Negotiate(header, []string{n.other, n.preferred}, strict)
+					require.NoError(t, err)
+					assert.Equal(t, n.preferred, result.Type, "the q parameter deprioritizes %s", n.other)
+				}
+
+				elements, err := n.negotiator.GetOrderedElements(header)
+				require.NoError(t, err)
+				require.Len(t, elements, 2)
+				assert.InDelta(t, 0.5, elements[1].Quality, 1e-9)
+				assert.True(t, elements[1].QualityExplicit)
+				assert.Empty(t, elements[1].Parameters, "q is not kept as a parameter")
+			})
+		}
+	}
+
+	coding, err := BestEncoding("gzip; Q=0.5, br", []string{"gzip", "br"})
+	require.NoError(t, err)
+	assert.Equal(t, "br", coding)
+}
+
+func FuzzGetOrderedElements(f *testing.F) {
+	seeds := []string{
+		"text/html, application/json;q=0.9, */*;q=0.8",
+		"text/html; foo=\"bar\", application/json",
+		"text/html; foo=\"bar,baz\", application/json",
+		"text/html; profile=\"\\\"http://example.com/profile\\\"\", application/json",
+		`text/html;q="unclosed, application/json`,
+		"text/html ; level = 2   ; q = 0.4",
+		"TEXT/hTmL;leVel=2; Q=0.4",
+		"invalid/header/format, text/html",
+		"zh-Hans-CN, en-US;q=0.5, *;q=0.1",
+		"\"",
+		"\\",
+		";",
+		",",
+		"=",
+		"*",
+		"text/html;q=NaN",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	negotiators := []*Negotiator{
+		NewMediaNegotiator(),
+		NewLanguageNegotiator(),
+		NewCharsetNegotiator(),
+		NewEncodingNegotiator(),
+	}
+
+	f.Fuzz(func(t *testing.T, header string) {
+		for _, negotiator := range negotiators {
+			elements, err := negotiator.GetOrderedElements(header)
+			if err == nil && elements == nil {
+				t.Fatalf("GetOrderedElements(%q) returned neither elements nor an error", header)
+			}
+
+			for _, e := range elements {
+				if e.Start < 0 || e.Start > e.End || e.End > len(header) || (e.End > 0 && header[e.Start:e.End] != e.Value) {
+					t.Fatalf("GetOrderedElements(%q) returned offsets [%d:%d] not matching %q", header, e.Start, e.End, e.Value)
+				}
+				reparsed, err := negotiator.parseElement(e.NormalizedValue)
+				if err != nil || reparsed.NormalizedValue != e.NormalizedValue {
+					t.Fatalf("GetOrderedElements(%q) returned normalized value %q that does not parse back: %v", header, e.NormalizedValue, err)
+				}
+				if !(e.Quality >= 0 && e.Quality <= 1) {
+					t.Fatalf("GetOrderedElements(%q) returned quality %v out of range", header, e.Quality)
+				}
+			}
+		}
+	})
+}
+
+func TestNegotiator_Negotiate_WildcardPriority(t *testing.T) {
+	tests := []struct {
+		name          string
+		negotiator    *Negotiator
+		header        string
+		priorities    []string
+		expectedValue string
+		expectedVia   MatchKind
+	}{
+		{
+			name:          "most preferred client element",
+			negotiator:    NewMediaNegotiator(),
+			header:        "text/html;q=0.5, application/json, application/xml;q=0.9",
+			priorities:    []string{"*/*"},
+			expectedValue: "application/json",
+			expectedVia:   MatchExact,
+		},
+		{
+			name:          "client order breaks quality ties",
+			negotiator:    NewMediaNegotiator(),
+			header:        "application/xml, application/json",
+			priorities:    []string{"*/*"},
+			expectedValue: "application/xml",
+			expectedVia:   MatchExact,
+		},
+		{
+			name:          "client parameters are kept",
+			negotiator:    NewMediaNegotiator(),
+			header:        "text/plain;q=0.2, Text/HTML; Level=1",
+			priorities:    []string{"*/*"},
+			expectedValue: "text/html; level=1",
+			expectedVia:   MatchExact,
+		},
+		{
+			name:          "ranges are skipped",
+			negotiator:    NewMediaNegotiator(),
+			header:        "text/*, application/*+json, image/png;q=0.1",
+			priorities:    []string{"*/*"},
+			expectedValue: "image/png",
+			expectedVia:   MatchExact,
+		},
+		{
+			name:          "rejected elements are skipped",
+			negotiator:    NewMediaNegotiator(),
+			header:        "text/html;q=0, application/json;q=0.3",
+			priorities:    []string{"*/*"},
+			expectedValue: "application/json",
+			expectedVia:   MatchExact,
+		},
+		{
+			name:          "client full wildcard only",
+			negotiator:    NewMediaNegotiator(),
+			header:        "*/*",
+			priorities:    []string{"*/*"},
+			expectedValue: "*/*",
+			expectedVia:   MatchFullWildcard,
+		},
+		{
+			name:          "earlier concrete priority wins ties",
+			negotiator:    NewMediaNegotiator(),
+			header:        "text/html, application/json",
+			priorities:    []string{"application/json", "*/*"},
+			expectedValue: "application/json",
+			expectedVia:   MatchExact,
+		},
+		{
+			name:          "wildcard serves what concrete priorities cannot",
+			negotiator:    NewMediaNegotiator(),
+			header:        "text/csv, application/json;q=0.5",
+			priorities:    []string{"application/json", "*/*"},
+			expectedValue: "text/csv",
+			expectedVia:   MatchExact,
+		},
+		{
+			name:          "language",
+			negotiator:    NewLanguageNegotiator(),
+			header:        "de;q=0.5, fr-ca",
+			priorities:    []string{"*"},
+			expectedValue: "fr-CA",
+			expectedVia:   MatchExact,
+		},
+		{
+			name:          "allowlist applies to client elements",
+			negotiator:    NewMediaNegotiator(WithAllowlist([]string{"*/*", "application/json"})),
+			header:        "text/html, application/json;q=0.5",
+			priorities:    []string{"*/*"},
+			expectedValue: "application/json",
+			expectedVia:   MatchExact,
+		},
+		{
+			name:          "type wildcard returns the client subtype",
+			negotiator:    NewMediaNegotiator(),
+			header:        "application/cbor",
+			priorities:    []string{"application/*"},
+			expectedValue: "application/cbor",
+			expectedVia:   MatchExact,
+		},
+		{
+			name:          "type wildcard skips other types",
+			negotiator:    NewMediaNegotiator(),
+			header:        "text/html, application/msgpack;q=0.5, application/cbor;q=0.8",
+			priorities:    []string{"application/*"},
+			expectedValue: "application/cbor",
+			expectedVia:   MatchExact,
+		},
+		{
+			name:          "type wildcard with client range",
+			negotiator:    NewMediaNegotiator(),
+			header:        "application/*",
+			priorities:    []string{"application/*"},
+			expectedValue: "application/*",
+			expectedVia:   MatchTypeWildcard,
+		},
+		{
+			name:          "type wildcard with client full wildcard",
+			negotiator:    NewMediaNegotiator(),
+			header:        "text/html, */*;q=0.1",
+			priorities:    []string{"application/*"},
+			expectedValue: "application/*",
+			expectedVia:   MatchFullWildcard,
+		},
+		{
+			name:          "allowlist applies to type wildcard",
+			negotiator:    NewMediaNegotiator(WithAllowlist([]string{"application/*", "application/json", "application/cbor"})),
+			header:        "application/xml, application/cbor;q=0.5, application/json;q=0.2",
+			priorities:    []string{"application/*"},
+			expectedValue: "application/cbor",
+			expectedVia:   MatchExact,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.negotiator.Negotiate(tt.header, tt.priorities, true)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedValue, result.Value)
+			assert.Equal(t, tt.expectedVia, result.MatchedVia)
+		})
+	}
+
+	_, err := NewMediaNegotiator().Negotiate("text/html;q=0", []string{"*/*"}, true)
+	require.ErrorIs(t, err, ErrNoAcceptableMatch)
+}
+
+func TestNegotiator_Negotiate_ParameterVariants(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+	versions := []string{"application/json; v=1", "application/json; v=2"}
+
+	tests := []struct {
+		name          string
+		acceptHeader  string
+		priorities    []string
+		expectedValue string
+		expectErr     error
+	}{
+		{"requested version", "application/json;v=2", versions, "application/json; v=2", nil},
+		{"requested version listed first", "application/json;v=1", versions, "application/json; v=1", nil},
+		{"parameter name and quotes", `application/json; V="2"`, versions, "application/json; v=2", nil},
+		{"no version prefers first variant", "application/json", versions, "application/json; v=1", nil},
+		{"wildcard prefers first variant", "*/*", versions, "application/json; v=1", nil},
+		{"version on type wildcard", "application/*;v=2", versions, "application/json; v=2", nil},
+		{"unknown version", "application/json;v=3", versions, "", ErrNoAcceptableMatch},
+		{"unknown version with fallback", "application/json;v=3, application/json;q=0.5", versions, "application/json; v=1", nil},
+		{"version quality", "application/json;v=2;q=0.5, application/json;v=1", versions, "application/json; v=1", nil},
+		{"rejected version", "application/json;v=1;q=0, application/json", versions, "application/json; v=2", nil},
+		{"version preferred over bare type", "application/json;q=0.5, application/json;v=2", versions, "application/json; v=2", nil},
+		{"versioned over unversioned priority", "application/json;v=2", []string{"application/json", "application/json; v=2"}, "application/json; v=2", nil},
+		{"other parameter", "application/json;schema-version=2", []string{"application/json; schema-version=1", "application/json; schema-version=2"}, "application/json; schema-version=2", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := negotiator.Negotiate(tt.acceptHeader, tt.priorities, true)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedValue, result.Value)
+		})
+	}
+}
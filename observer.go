@@ -0,0 +1,12 @@
+package negotiation
+
+// Observer is notified of negotiation outcomes, see WithObserver.
+// Implementations must be safe for concurrent use if the negotiator is shared.
+type Observer interface {
+	// OnMatch is called when a priority was chosen. headerName is the negotiated
+	// request header (e.g. "Accept"), chosen the priority as given and quality
+	// the quality the client assigned to it.
+	OnMatch(headerName, chosen string, quality float64)
+	// OnNoMatch is called when no priority was acceptable (ErrNoAcceptableMatch).
+	OnNoMatch(headerName string)
+}
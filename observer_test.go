@@ -0,0 +1,74 @@
+package negotiation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingObserver struct {
+	matches   []string
+	qualities []float64
+	noMatches []string
+}
+
+func (o *recordingObserver) OnMatch(headerName, chosen string, quality float64) {
+	o.matches = append(o.matches, headerName+" "+chosen)
+	o.qualities = append(o.qualities, quality)
+}
+
+func (o *recordingObserver) OnNoMatch(headerName string) {
+	o.noMatches = append(o.noMatches, headerName)
+}
+
+func TestWithObserver(t *testing.T) {
+	observer := &recordingObserver{}
+	media := NewMediaNegotiator(WithObserver(observer))
+	language := NewLanguageNegotiator(WithObserver(observer))
+
+	_, err := media.Negotiate("text/html;q=0.8, application/json;q=0.5", []string{"application/json", "Text/HTML"}, false)
+	require.NoError(t, err)
+
+	_, err = language.Negotiate("fr", []string{"en"}, false)
+	require.ErrorIs(t, err, ErrNoAcceptableMatch)
+
+	_, _, err = media.NegotiateWithTrace("application/json", []string{"application/json"}, false)
+	require.NoError(t, err)
+
+	// Invalid input and acceptability checks are not reported.
+	_, err = media.Negotiate("", []string{"text/html"}, false)
+	require.ErrorIs(t, err, ErrEmptyHeader)
+
+	_, err = media.Acceptable("text/html", "text/html", false)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"Accept Text/HTML", "Accept application/json"}, observer.matches)
+	assert.Equal(t, []float64{0.8, 1.0}, observer.qualities)
+	assert.Equal(t, []string{"Accept-Language"}, observer.noMatches)
+}
+
+func TestWithObserver_HeaderNames(t *testing.T) {
+	tests := []struct {
+		negotiator func(opts ...Option) *Negotiator
+		header     string
+		priority   string
+		expected   string
+	}{
+		{NewMediaNegotiator, "*/*", "text/html", "Accept text/html"},
+		{NewLanguageNegotiator, "*", "en", "Accept-Language en"},
+		{NewCharsetNegotiator, "*", "utf-8", "Accept-Charset utf-8"},
+		{NewEncodingNegotiator, "*", "gzip", "Accept-Encoding gzip"},
+		{NewTENegotiator, "trailers", "trailers", "TE trailers"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			observer := &recordingObserver{}
+
+			_, err := tt.negotiator(WithObserver(observer)).Negotiate(tt.header, []string{tt.priority}, false)
+			require.NoError(t, err)
+			assert.Equal(t, []string{tt.expected}, observer.matches)
+		})
+	}
+}
@@ -0,0 +1,345 @@
+package negotiation
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+)
+
+// Option configures a Negotiator.
+type Option func(*options)
+
+// options holds the configurable behavior of a Negotiator.
+type options struct {
+	// caseSensitiveParamValues makes parameter values compare case-sensitively.
+	caseSensitiveParamValues bool
+	// exactParameterMatch requires priorities to specify every client parameter.
+	exactParameterMatch bool
+	// languageFallback lets language ranges match priorities by base subtag only.
+	languageFallback bool
+	// typeNormalizer canonicalizes types of header elements and priorities.
+	typeNormalizer func(string) string
+	// clientPreferenceWins breaks quality ties by client element order instead of priority order.
+	clientPreferenceWins bool
+	// rfc7231Strict separates accept-ext parameters following q from range parameters.
+	rfc7231Strict bool
+	// allowlist holds the only types priorities may have; nil allows any type.
+	allowlist map[string]bool
+	// observer is notified of negotiation outcomes; nil disables notifications.
+	observer Observer
+	// emptyHeader is negotiated in place of an empty header; empty means an error.
+	emptyHeader string
+	// charsetAliases are extra charset aliases mapped to canonical names.
+	charsetAliases map[string]string
+	// charsetParamMatching compares media type charset parameters across aliases.
+	charsetParamMatching bool
+	// charsetParamAliases is the alias table used for charset parameters,
+	// set by NewMediaNegotiator when charsetParamMatching is enabled.
+	charsetParamAliases map[string]string
+	// commaDecimalTolerance reads comma decimal q values ("q=0,8") as dot decimals.
+	commaDecimalTolerance bool
+	// resultCacheSize is the number of negotiation results memoized; 0 disables the cache.
+	resultCacheSize int
+	// treeMatching lets media types of a registration tree match more general priorities of the same tree.
+	treeMatching bool
+	// defaultQuality is the quality of header elements without a q parameter,
+	// used instead of 1.0 when hasDefaultQuality is set.
+	defaultQuality    float64
+	hasDefaultQuality bool
+	// preserveCase returns types with the casing they were given in.
+	preserveCase bool
+	// macrolanguageMatching lets languages match their macrolanguage and vice versa.
+	macrolanguageMatching bool
+	// macrolanguageExtras are extra languages mapped to their macrolanguage.
+	macrolanguageExtras map[string]string
+	// macrolanguages is the macrolanguage table, set by NewLanguageNegotiator
+	// when macrolanguageMatching is enabled.
+	macrolanguages map[string]string
+	// tiebreakDefault is the priority preferred among equally acceptable ones.
+	tiebreakDefault string
+	// strictPriorities parses priorities strictly whatever the header strictness.
+	strictPriorities bool
+	// rejectWildcardOnly fails negotiation for clients accepting only full wildcards.
+	rejectWildcardOnly bool
+	// implicitIdentity falls back to the identity coding when no priority is acceptable.
+	implicitIdentity bool
+}
+
+// WithCaseSensitiveParamValues controls how parameter values are compared during matching.
+// Parameter names are always compared case-insensitively. Values are compared
+// case-insensitively by default so that e.g. charset=utf-8 matches charset=UTF-8;
+// enabling this option requires values to match exactly.
+func WithCaseSensitiveParamValues(enabled bool) Option {
+	return func(o *options) {
+		o.caseSensitiveParamValues = enabled
+	}
+}
+
+// WithExactParameterMatch controls how client parameters the priority does not
+// specify are treated. By default they are ignored, so "text/html;foo=bar" accepts a
+// "text/html" priority; when enabled, a priority must specify every client parameter
+// to match. Parameters specified by both must always have equal values, and
+// parameters only the priority specifies never prevent a match.
+func WithExactParameterMatch(enabled bool) Option {
+	return func(o *options) {
+		o.exactParameterMatch = enabled
+	}
+}
+
+// WithLanguageFallback lets the language negotiator fall back to the base subtag
+// when no regional match exists, so a client asking for en-GB can be served en-US.
+// Exact matches are still preferred over fallback matches of equal quality, and
+// a fallback match is reported as MatchLanguageFallback.
+// The option only affects language negotiation.
+func WithLanguageFallback(enabled bool) Option {
+	return func(o *options) {
+		o.languageFallback = enabled
+	}
+}
+
+// WithTypeNormalizer installs a hook that canonicalizes the type of every header
+// element and priority before matching. The hook receives the lowercased type
+// without parameters and returns the type to match with, e.g. mapping
+// "application/vnd.myapi.v2+json" to "application/json". The result must be a
+// valid type for the negotiator; an invalid one fails parsing of that entry.
+func WithTypeNormalizer(normalize func(string) string) Option {
+	return func(o *options) {
+		o.typeNormalizer = normalize
+	}
+}
+
+// WithClientPreferenceWins controls how priorities accepted with equal quality are ordered.
+// By default the server priority order decides. When enabled, the priority matched
+// by the earlier client element wins instead, e.g. "application/json, text/html"
+// selects application/json even if text/html is listed first. Priorities matched
+// by the same element, such as */*, still follow the priority order.
+// The option has no effect when a custom comparator is set.
+func WithClientPreferenceWins(enabled bool) Option {
+	return func(o *options) {
+		o.clientPreferenceWins = enabled
+	}
+}
+
+// WithRFC7231Strict parses elements following the RFC 7231 grammar: parameters
+// before q belong to the range and are used for matching, while parameters after q
+// are accept extensions, recorded in Extensions and ignored for matching. Elements
+// with a repeated q or an extension named like a range parameter are invalid, so
+// strict negotiation reports them and lenient negotiation skips them.
+// Without this option, parameters on both sides of q are matched alike.
+func WithRFC7231Strict() Option {
+	return func(o *options) {
+		o.rfc7231Strict = true
+	}
+}
+
+// WithStrictPriorities makes negotiation fail on an invalid priority even when the
+// header is parsed leniently, i.e. when strict is false. Priorities are server
+// configuration, so this surfaces a misconfigured priority list while messy
+// client headers are still tolerated. The error is the parse error of the priority,
+// as in strict mode; it is never classified as ErrMalformedHeader.
+func WithStrictPriorities(enabled bool) Option {
+	return func(o *options) {
+		o.strictPriorities = enabled
+	}
+}
+
+// WithRejectWildcardOnly makes negotiation fail with ErrWildcardOnly when every
+// element the client accepts with a positive quality is a full wildcard ("*/*",
+// or "*" for other headers), as sent by callers that declare no preference, instead
+// of picking the first priority. A single concrete type or range such as "text/*"
+// among them is enough: "application/json, */*" negotiates as usual. A header
+// without any acceptable element still fails with ErrNoAcceptableMatch.
+func WithRejectWildcardOnly(enabled bool) Option {
+	return func(o *options) {
+		o.rejectWildcardOnly = enabled
+	}
+}
+
+// WithImplicitIdentity makes the identity coding acceptable by default, as RFC
+// 7231 (section 5.3.4) specifies for Accept-Encoding: when no priority is acceptable,
+// negotiation returns the identity priority, or "identity" if the priorities lack
+// it, unless the header excludes it with "identity;q=0" or, lacking an identity
+// element, "*;q=0". The implicit identity is a last resort only: a client sending
+// "gzip" gets gzip even if identity is listed first. By default identity is
+// acceptable only if the header names it or "*". The fallback only applies when
+// negotiation picks a priority: Acceptable, QualityOf, StillAcceptable and Rejected
+// judge each priority on the header alone. BestEncoding enables this option.
+func WithImplicitIdentity(enabled bool) Option {
+	return func(o *options) {
+		o.implicitIdentity = enabled
+	}
+}
+
+// WithAllowlist restricts negotiation to priorities whose type is in types, so a
+// priority list built from untrusted input can never resolve to an unintended type,
+// whatever the client accepts. Other priorities are skipped as if absent and reported
+// by ValidatePriorities. Types are compared case-insensitively without parameters
+// and after the type normalizer, e.g. "application/json" allows
+// "application/json; charset=utf-8".
+func WithAllowlist(types []string) Option {
+	return func(o *options) {
+		o.allowlist = make(map[string]bool, len(types))
+		for _, typ := range types {
+			typ, _, _ = strings.Cut(typ, ";")
+			o.allowlist[strings.ToLower(strings.TrimSpace(typ))] = true
+		}
+	}
+}
+
+// WithObserver installs an Observer notified after each Negotiate, NegotiateWeighted
+// and NegotiateWithTrace call that parsed its input, e.g. to count outcomes in metrics.
+// Calls failing with other errors than ErrNoAcceptableMatch are not reported.
+func WithObserver(observer Observer) Option {
+	return func(o *options) {
+		o.observer = observer
+	}
+}
+
+// WithEmptyHeader sets the header negotiated in place of a header that is empty
+// after trimming. By default an empty header fails with ErrEmptyHeader, except for
+// the encoding negotiator, where it means "identity" only. Use "*" to accept
+// anything, or "" to restore the error.
+func WithEmptyHeader(header string) Option {
+	return func(o *options) {
+		o.emptyHeader = header
+	}
+}
+
+// WithCharsetAliases extends the built-in charset alias table, mapping each alias
+// to its canonical name, e.g. "x-mac-roman" to "macintosh". Aliases are resolved
+// before matching, so a client sending "utf8" matches a "utf-8" priority.
+// The option affects charset negotiation and media type charset parameters
+// compared with WithCharsetParamMatching.
+func WithCharsetAliases(aliases map[string]string) Option {
+	return func(o *options) {
+		if o.charsetAliases == nil {
+			o.charsetAliases = make(map[string]string, len(aliases))
+		}
+		maps.Copy(o.charsetAliases, aliases)
+	}
+}
+
+// WithCharsetParamMatching makes the media negotiator compare the charset parameter
+// as a charset: across aliases and case-insensitively even with case-sensitive
+// parameter values, so "text/html;charset=utf8" accepts a "text/html; charset=UTF-8"
+// priority. As for any parameter, a range with a charset only matches priorities
+// that specify one. The option only affects media type negotiation.
+func WithCharsetParamMatching(enabled bool) Option {
+	return func(o *options) {
+		o.charsetParamMatching = enabled
+	}
+}
+
+// WithMacrolanguageMatching lets a language range match priorities related to it
+// through a macrolanguage: a client asking for "no" accepts "nb" and "nn", and a
+// client asking for "cmn-Hans" accepts "zh-Hans". Script and region subtags must
+// still be compatible as for regular matches. Macrolanguage matches are less
+// specific than any match of the same primary subtag, including base fallbacks,
+// and like those lose quality ties against regular matches. A built-in table covers common cases such
+// as Chinese, Norwegian, Arabic and Persian; extend it with WithMacrolanguages.
+// The option only affects language negotiation.
+func WithMacrolanguageMatching(enabled bool) Option {
+	return func(o *options) {
+		o.macrolanguageMatching = enabled
+	}
+}
+
+// WithMacrolanguages extends the built-in macrolanguage table used by
+// WithMacrolanguageMatching, mapping each individual language to its
+// macrolanguage, e.g. "ckb" to "ku".
+func WithMacrolanguages(table map[string]string) Option {
+	return func(o *options) {
+		if o.macrolanguageExtras == nil {
+			o.macrolanguageExtras = make(map[string]string, len(table))
+		}
+		maps.Copy(o.macrolanguageExtras, table)
+	}
+}
+
+// WithTiebreakDefault sets the priority that wins when the client has no
+// preference between it and other acceptable priorities, e.g. because it only
+// sends "*/*" or several ranges of equal quality, instead of the earliest
+// priority. It only breaks ties: it must be among the priorities and accepted
+// with the highest quality to be chosen, so it never selects a priority the client
+// rejects and never turns a failed negotiation into a match. It takes precedence
+// over weights and costs, but not over WithClientPreferenceWins, and has no effect
+// with a custom comparator. It is compared to priorities after normalization, so
+// "Application/JSON" designates the "application/json" priority; a default that
+// is invalid for the negotiator never matches.
+func WithTiebreakDefault(priority string) Option {
+	return func(o *options) {
+		o.tiebreakDefault = priority
+	}
+}
+
+// WithTreeMatching lets media types in the vendor (vnd.), personal (prs.) and
+// unregistered (x.) trees of RFC 6838 match a more general priority of the same
+// tree: a client "application/vnd.company.invoice+json" accepts the priorities
+// "application/vnd.company+json" and "application/vnd+json", so one priority can
+// serve a family of vendor types. Base type and suffix must still match, and
+// types of different trees never match each other. Tree matches are less
+// specific than exact subtype matches but more specific than type wildcards,
+// and lose quality ties against priorities the client named exactly.
+// The option only affects media type negotiation.
+func WithTreeMatching(enabled bool) Option {
+	return func(o *options) {
+		o.treeMatching = enabled
+	}
+}
+
+// WithCommaDecimalTolerance makes the negotiator read a q value with a comma
+// decimal separator, as sent by some clients with a comma-decimal locale
+// ("text/html;q=0,8"), as the dot decimal it stands for, in strict mode too.
+// By default such a q value is malformed: the comma splits the element, so strict
+// negotiation fails and lenient negotiation skips the stray digits.
+func WithCommaDecimalTolerance(enabled bool) Option {
+	return func(o *options) {
+		o.commaDecimalTolerance = enabled
+	}
+}
+
+// WithResultCache memoizes the results of Negotiate, NegotiateWeighted and
+// NegotiateServerQuality, keyed by the raw header, the priority list and the mode,
+// for handlers with a fixed priority list that see the same headers repeatedly.
+// Results are returned as copies, and a changed priority list never hits a result
+// cached for the old one. At most size results are kept, evicting arbitrary entries
+// when full; a size of 0 or less disables the cache. The observer is still notified
+// on cache hits. The cache is safe for concurrent use.
+func WithResultCache(size int) Option {
+	return func(o *options) {
+		o.resultCacheSize = size
+	}
+}
+
+// WithDefaultQuality sets the quality of header elements without an explicit q
+// parameter, 1.0 by default as the specification requires. A lower default lets
+// explicit client preferences outrank unspecified entries, e.g. with a default of
+// 0.5, "text/html, application/json;q=0.8" prefers application/json. Elements
+// whose malformed q is ignored in lenient mode get the default too. Priorities
+// are not affected. WithDefaultQuality panics with an *InvalidArgumentError if q
+// is not within [0, 1].
+func WithDefaultQuality(q float64) Option {
+	if !(q >= 0 && q <= 1) {
+		panic(&InvalidArgumentError{Message: fmt.Sprintf("default quality %v is not within [0, 1]", q)})
+	}
+
+	return func(o *options) {
+		o.defaultQuality = q
+		o.hasDefaultQuality = true
+	}
+}
+
+// WithPreserveCase makes returned headers keep the type with the casing it was
+// given in, e.g. "Application/JSON" instead of "application/json", for systems
+// echoing headers verbatim. The Type of the priority returned by negotiation
+// keeps the casing of the client element naming it; a priority matched through
+// a range such as "*/*" keeps its own casing, and Value stays the priority as
+// given. It also applies to the client element of a Match and the elements
+// returned by GetOrderedElements, Elements and ParseElements. Matching stays
+// case-insensitive, and NormalizedValue and Normalize stay lowercase. Types
+// replaced by an alias or the type normalizer are returned as replaced.
+func WithPreserveCase(enabled bool) Option {
+	return func(o *options) {
+		o.preserveCase = enabled
+	}
+}
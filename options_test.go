@@ -0,0 +1,931 @@
+package negotiation
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithCaseSensitiveParamValues(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          []Option
+		acceptHeader  string
+		priorities    []string
+		expectedValue string
+		expectErr     error
+	}{
+		{
+			name:          "default matches values case-insensitively",
+			acceptHeader:  "text/html;charset=UTF-8",
+			priorities:    []string{"text/html;charset=utf-8"},
+			expectedValue: "text/html;charset=utf-8",
+		},
+		{
+			name:          "parameter names are always case-insensitive",
+			opts:          []Option{WithCaseSensitiveParamValues(true)},
+			acceptHeader:  "text/html;CHARSET=UTF-8",
+			priorities:    []string{"text/html;charset=UTF-8"},
+			expectedValue: "text/html;charset=UTF-8",
+		},
+		{
+			name:         "case-sensitive values reject differing case",
+			opts:         []Option{WithCaseSensitiveParamValues(true)},
+			acceptHeader: "text/html;charset=UTF-8",
+			priorities:   []string{"text/html;charset=utf-8"},
+			expectErr:    ErrNoAcceptableMatch,
+		},
+		{
+			name:          "case-sensitive values pick the exact variant",
+			opts:          []Option{WithCaseSensitiveParamValues(true)},
+			acceptHeader:  "text/html;charset=UTF-8",
+			priorities:    []string{"text/html;charset=utf-8", "text/html;charset=UTF-8"},
+			expectedValue: "text/html;charset=UTF-8",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			negotiator := NewMediaNegotiator(tt.opts...)
+			result, err := negotiator.Negotiate(tt.acceptHeader, tt.priorities, false)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedValue, result.Value)
+		})
+	}
+}
+
+func TestWithLanguageFallback(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         []Option
+		acceptHeader string
+		priorities   []string
+		expectedType string
+		expectedVia  MatchKind
+		expectErr    error
+	}{
+		{
+			name:         "base priority matches without option",
+			acceptHeader: "en-GB",
+			priorities:   []string{"en-US", "en"},
+			expectedType: "en",
+			expectedVia:  MatchExact,
+		},
+		{
+			name:         "other region does not match without option",
+			acceptHeader: "en-GB",
+			priorities:   []string{"en-US"},
+			expectErr:    ErrNoAcceptableMatch,
+		},
+		{
+			name:         "falls back to other region",
+			opts:         []Option{WithLanguageFallback(true)},
+			acceptHeader: "en-GB",
+			priorities:   []string{"en-US"},
+			expectedType: "en-us",
+			expectedVia:  MatchLanguageFallback,
+		},
+		{
+			name:         "exact match preferred over fallback",
+			opts:         []Option{WithLanguageFallback(true)},
+			acceptHeader: "en-GB",
+			priorities:   []string{"en-US", "en-GB"},
+			expectedType: "en-gb",
+			expectedVia:  MatchExact,
+		},
+		{
+			name:         "base priority preferred over fallback",
+			opts:         []Option{WithLanguageFallback(true)},
+			acceptHeader: "en-GB",
+			priorities:   []string{"en-US", "en"},
+			expectedType: "en",
+			expectedVia:  MatchExact,
+		},
+		{
+			name:         "fallback keeps client quality",
+			opts:         []Option{WithLanguageFallback(true)},
+			acceptHeader: "en-GB, fr;q=0.5",
+			priorities:   []string{"fr", "en-US"},
+			expectedType: "en-us",
+			expectedVia:  MatchLanguageFallback,
+		},
+		{
+			name:         "different base never matches",
+			opts:         []Option{WithLanguageFallback(true)},
+			acceptHeader: "fr",
+			priorities:   []string{"en", "en-US"},
+			expectErr:    ErrNoAcceptableMatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			negotiator := NewLanguageNegotiator(tt.opts...)
+			result, err := negotiator.Negotiate(tt.acceptHeader, tt.priorities, false)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedType, result.Type)
+			assert.Equal(t, tt.expectedVia, result.MatchedVia)
+		})
+	}
+}
+
+func TestWithTypeNormalizer(t *testing.T) {
+	stripVendor := func(typ string) string {
+		if typ == "application/vnd.myapi.v2+json" {
+			return "application/json"
+		}
+
+		return typ
+	}
+
+	negotiator := NewMediaNegotiator(WithTypeNormalizer(stripVendor))
+
+	// Applied to header elements.
+	result, err := negotiator.Negotiate("application/vnd.myapi.v2+json", []string{"text/html", "application/json"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", result.Type)
+
+	// Applied to priorities; Value keeps the original input.
+	result, err = negotiator.Negotiate("application/json;q=0.9, text/html;q=0.5", []string{"text/html", "application/vnd.myapi.v2+json"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", result.Type)
+	assert.Equal(t, "json", result.SubPart)
+	assert.Equal(t, "application/vnd.myapi.v2+json", result.Value)
+
+	// Parameters survive normalization.
+	elements, err := negotiator.GetOrderedElements("application/vnd.myapi.v2+json;charset=utf-8")
+	require.NoError(t, err)
+	require.Len(t, elements, 1)
+	assert.Equal(t, "application/json; charset=utf-8", elements[0].NormalizedValue)
+
+	// Invalid normalized types are rejected like invalid input.
+	broken := NewMediaNegotiator(WithTypeNormalizer(func(string) string { return "broken" }))
+	_, err = broken.Negotiate("text/html", []string{"text/html"}, true)
+	assert.IsType(t, &InvalidMediaTypeError{}, err)
+}
+
+func TestWithCharsetAliases(t *testing.T) {
+	negotiator := NewCharsetNegotiator(WithCharsetAliases(map[string]string{"X-Mac-Roman": "macintosh"}))
+
+	result, err := negotiator.Negotiate("x-mac-roman", []string{"utf-8", "macintosh"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "macintosh", result.Type)
+
+	// Built-in aliases are still resolved.
+	result, err = negotiator.Negotiate("utf8", []string{"macintosh", "utf-8"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "utf-8", result.Type)
+
+	// Other negotiators ignore the option.
+	_, err = NewEncodingNegotiator(WithCharsetAliases(map[string]string{"x-gzip": "gzip"})).
+		Negotiate("x-gzip", []string{"gzip"}, false)
+	require.ErrorIs(t, err, ErrNoAcceptableMatch)
+}
+
+func TestWithClientPreferenceWins(t *testing.T) {
+	priorities := []string{"text/html", "application/json"}
+
+	tests := []struct {
+		name         string
+		enabled      bool
+		acceptHeader string
+		expected     string
+	}{
+		{"server order by default", false, "application/json, text/html", "text/html"},
+		{"client order when enabled", true, "application/json, text/html", "application/json"},
+		{"quality still wins", true, "application/json;q=0.5, text/html", "text/html"},
+		{"full wildcard keeps server order", true, "*/*", "text/html"},
+		{"shared range keeps server order", true, "image/png, */*", "text/html"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			negotiator := NewMediaNegotiator(WithClientPreferenceWins(tt.enabled))
+
+			result, err := negotiator.Negotiate(tt.acceptHeader, priorities, false)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Type)
+		})
+	}
+}
+
+func TestWithRFC7231Strict(t *testing.T) {
+	negotiator := NewMediaNegotiator(WithRFC7231Strict())
+
+	// Parameters after q are accept extensions and do not constrain matching.
+	result, trace, err := negotiator.NegotiateWithTrace("text/html;q=0.5;level=1", []string{"text/html;level=2"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "text/html", result.Type)
+	require.NotNil(t, trace.Priorities[0].Element)
+	assert.Equal(t, map[string]string{"level": "1"}, trace.Priorities[0].Element.Extensions)
+	assert.Empty(t, trace.Priorities[0].Element.Parameters)
+	assert.Equal(t, "text/html;q=0.5;level=1", trace.Priorities[0].Element.Value)
+
+	// Parameters before q still do.
+	_, err = negotiator.Negotiate("text/html;level=1;q=0.5", []string{"text/html;level=2"}, true)
+	require.ErrorIs(t, err, ErrNoAcceptableMatch)
+
+	// Ambiguous orderings are rejected in strict mode and skipped otherwise.
+	_, err = negotiator.Negotiate("text/html;level=1;q=0.5;level=2", []string{"text/html"}, true)
+	assert.IsType(t, &InvalidHeaderError{}, err)
+
+	_, err = negotiator.Negotiate("text/html;q=0.5;q=0.9", []string{"text/html"}, true)
+	assert.IsType(t, &InvalidHeaderError{}, err)
+
+	result, err = negotiator.Negotiate("text/html;q=0.5;q=0.9, application/json;q=0.1", []string{"text/html", "application/json"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", result.Type)
+
+	// Without the option parameters after q are matched as before.
+	_, err = NewMediaNegotiator().Negotiate("text/html;q=0.5;level=1", []string{"text/html;level=2"}, true)
+	require.ErrorIs(t, err, ErrNoAcceptableMatch)
+}
+
+func TestWithCharsetParamMatching(t *testing.T) {
+	priorities := []string{"text/html; charset=UTF-8", "text/html; charset=iso-8859-1"}
+
+	negotiator := NewMediaNegotiator(WithCharsetParamMatching(true), WithCaseSensitiveParamValues(true))
+
+	result, err := negotiator.Negotiate("text/html;charset=latin1", priorities, true)
+	require.NoError(t, err)
+	assert.Equal(t, "iso-8859-1", CharsetOf(result))
+
+	result, err = negotiator.Negotiate("text/html;charset=utf8", priorities, true)
+	require.NoError(t, err)
+	assert.Equal(t, "UTF-8", CharsetOf(result))
+
+	// Ranges without a charset accept any charset.
+	result, err = negotiator.Negotiate("text/html", priorities, true)
+	require.NoError(t, err)
+	assert.Equal(t, "UTF-8", CharsetOf(result))
+
+	// Custom charset aliases apply to the parameter too.
+	custom := NewMediaNegotiator(WithCharsetParamMatching(true), WithCharsetAliases(map[string]string{"x-mac-roman": "macintosh"}))
+	_, err = custom.Negotiate("text/plain;charset=x-mac-roman", []string{"text/plain;charset=macintosh"}, true)
+	require.NoError(t, err)
+
+	// Without the option aliases do not match.
+	_, err = NewMediaNegotiator().Negotiate("text/html;charset=utf8", priorities, true)
+	require.ErrorIs(t, err, ErrNoAcceptableMatch)
+}
+
+func TestWithExactParameterMatch(t *testing.T) {
+	tests := []struct {
+		name         string
+		exact        bool
+		acceptHeader string
+		priorities   []string
+		expected     string
+		expectErr    error
+	}{
+		{"extra client params ignored", false, "text/html;charset=utf-8;foo=bar", []string{"text/html"}, "text/html", nil},
+		{"extra client params required", true, "text/html;charset=utf-8;foo=bar", []string{"text/html"}, "", ErrNoAcceptableMatch},
+		{"all client params specified", true, "text/html;charset=utf-8", []string{"text/html;charset=UTF-8"}, "text/html; charset=UTF-8", nil},
+		{"conflicting values never match", false, "text/html;charset=utf-8", []string{"text/html;charset=iso-8859-1"}, "", ErrNoAcceptableMatch},
+		{"priority params without client params", false, "text/html", []string{"text/html;level=1"}, "text/html; level=1", nil},
+		{"priority params without client params exact", true, "text/html", []string{"text/html;level=1"}, "text/html; level=1", nil},
+		{"full match wins ties", false, "text/html;level=1", []string{"text/html", "text/html;level=1"}, "text/html; level=1", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			negotiator := NewMediaNegotiator(WithExactParameterMatch(tt.exact))
+
+			result, err := negotiator.Negotiate(tt.acceptHeader, tt.priorities, true)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.NormalizedValue)
+		})
+	}
+
+	// A range fully matching the priority's parameters resolves its quality
+	// even when a partly matching range of equal specificity comes first.
+	_, trace, err := NewMediaNegotiator().NegotiateWithTrace("text/html;level=1;q=0.3, text/html;q=0.7", []string{"text/html"}, true)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.7, trace.Priorities[0].Quality, 1e-9)
+}
+
+func TestWithAllowlist(t *testing.T) {
+	negotiator := NewMediaNegotiator(WithAllowlist([]string{"application/json", "Text/HTML; charset=utf-8"}))
+
+	tests := []struct {
+		name         string
+		acceptHeader string
+		priorities   []string
+		expected     string
+		expectErr    error
+	}{
+		{"wildcard constrained to allowlist", "*/*", []string{"text/x-unsafe", "application/json"}, "application/json", nil},
+		{"type wildcard constrained to allowlist", "text/*", []string{"text/javascript", "text/html"}, "text/html", nil},
+		{"explicit request for unlisted type", "image/svg+xml, */*;q=0.1", []string{"image/svg+xml", "application/json"}, "application/json", nil},
+		{"no allowlisted priority", "*/*", []string{"text/x-unsafe"}, "", ErrNoAcceptableMatch},
+		{"wildcard priority not allowlisted", "*/*", []string{"*/*"}, "", ErrNoAcceptableMatch},
+		{"parameters ignored", "application/*", []string{"application/json; charset=utf-8"}, "application/json", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := negotiator.Negotiate(tt.acceptHeader, tt.priorities, true)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Type)
+		})
+	}
+
+	err := negotiator.ValidatePriorities([]string{"application/json", "text/x-unsafe"})
+	require.ErrorIs(t, err, ErrNotAllowed)
+	assert.Contains(t, err.Error(), `priority 1 "text/x-unsafe"`)
+
+	// The allowlist applies after the type normalizer.
+	normalized := NewMediaNegotiator(
+		WithAllowlist([]string{"application/json"}),
+		WithTypeNormalizer(func(string) string { return "application/json" }),
+	)
+	result, err := normalized.Negotiate("*/*", []string{"application/vnd.api+json"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", result.Type)
+}
+
+func TestWithTreeMatching(t *testing.T) {
+	tests := []struct {
+		name         string
+		enabled      bool
+		acceptHeader string
+		priorities   []string
+		expected     string
+		expectedVia  MatchKind
+		expectErr    error
+	}{
+		{"disabled", false, "application/vnd.company.invoice+json", []string{"application/vnd.company+json"}, "", "", ErrNoAcceptableMatch},
+		{"general vendor facet", true, "application/vnd.company.invoice+json", []string{"application/vnd.company+json"}, "application/vnd.company+json", MatchTree, nil},
+		{"whole vendor tree", true, "application/vnd.company+json", []string{"application/vnd+json"}, "application/vnd+json", MatchTree, nil},
+		{"vendor does not match personal", true, "application/vnd.company+json", []string{"application/prs.company+json", "application/prs+json"}, "", "", ErrNoAcceptableMatch},
+		{"personal does not match vendor", true, "application/prs.alice+json", []string{"application/vnd+json", "application/prs+json"}, "application/prs+json", MatchTree, nil},
+		{"suffix must match", true, "application/vnd.company+json", []string{"application/vnd.company+xml", "application/vnd.company"}, "", "", ErrNoAcceptableMatch},
+		{"base must match", true, "application/vnd.company+json", []string{"text/vnd.company+json"}, "", "", ErrNoAcceptableMatch},
+		{"exact subtype preferred", true, "application/vnd.company.invoice+json", []string{"application/vnd.company+json", "application/vnd.company.invoice+json"}, "application/vnd.company.invoice+json", MatchExact, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			negotiator := NewMediaNegotiator(WithTreeMatching(tt.enabled))
+
+			result, err := negotiator.Negotiate(tt.acceptHeader, tt.priorities, true)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Value)
+			assert.Equal(t, tt.expectedVia, result.MatchedVia)
+		})
+	}
+
+	// A tree match is more specific than a type wildcard.
+	negotiator := NewMediaNegotiator(WithTreeMatching(true))
+	_, trace, err := negotiator.NegotiateWithTrace("application/*;q=0.2, application/vnd.company.invoice+json;q=0.9", []string{"application/vnd.company+json"}, true)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.9, trace.Priorities[0].Quality, 1e-9)
+}
+
+func TestWithCommaDecimalTolerance(t *testing.T) {
+	header := "text/html;q=0,8, application/json;q=0,5, */*;q=0,1"
+	priorities := []string{"application/json", "text/html"}
+
+	// By default the comma splits the element and strict negotiation fails.
+	_, err := NewMediaNegotiator().Negotiate(header, priorities, true)
+	assert.IsType(t, &InvalidMediaTypeError{}, err)
+
+	tolerant := NewMediaNegotiator(WithCommaDecimalTolerance(true))
+	for _, strict := range []bool{true, false} {
+		result, err := tolerant.Negotiate(header, priorities, strict)
+		require.NoError(t, err)
+		assert.Equal(t, "text/html", result.Value)
+	}
+
+	elements, err := tolerant.GetOrderedElements(header)
+	require.NoError(t, err)
+	require.Len(t, elements, 3)
+	assert.InDelta(t, 0.8, elements[0].Quality, 1e-9)
+	assert.InDelta(t, 0.5, elements[1].Quality, 1e-9)
+	assert.InDelta(t, 0.1, elements[2].Quality, 1e-9)
+	for _, e := range elements {
+		assert.Equal(t, e.Value, header[e.Start:e.End], "Value keeps the comma")
+	}
+	assert.Equal(t, "text/html;q=0,8", elements[0].Value)
+}
+
+func TestWithCommaDecimalTolerance_OnlyQValues(t *testing.T) {
+	negotiator := NewMediaNegotiator(WithCommaDecimalTolerance(true))
+
+	tests := []struct {
+		name     string
+		header   string
+		expected []string
+	}{
+		{"spaced q", "text/html; q = 0,8", []string{"text/html"}},
+		{"space after comma splits", "text/html;q=0, 8", []string{"text/html"}},
+		{"parameter other than q", "text/html;level=1,2", []string{"text/html"}},
+		{"quoted comma", `text/html;foo="q=0,8", application/json`, []string{"text/html", "application/json"}},
+		{"element starting with digit", "text/html,2/x", []string{"text/html", "2/x"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			elements, err := negotiator.GetOrderedElements(tt.header)
+			require.NoError(t, err)
+
+			types := make([]string, 0, len(elements))
+			for _, e := range elements {
+				types = append(types, e.Type)
+			}
+			assert.ElementsMatch(t, tt.expected, types)
+		})
+	}
+
+	elements, err := negotiator.GetOrderedElements("text/html; q = 0,8")
+	require.NoError(t, err)
+	assert.InDelta(t, 0.8, elements[0].Quality, 1e-9)
+}
+
+func TestWithDefaultQuality(t *testing.T) {
+	header := "text/html, application/json;q=0.8, application/xml;q=1"
+	priorities := []string{"text/html", "application/json", "application/xml"}
+
+	result, err := NewMediaNegotiator().Negotiate(header, priorities, true)
+	require.NoError(t, err)
+	assert.Equal(t, "text/html", result.Value, "missing q defaults to 1.0")
+
+	tests := []struct {
+		name     string
+		quality  float64
+		expected string
+	}{
+		{"explicit preference outranks default", 0.5, "application/xml"},
+		{"default equal to explicit", 1, "text/html"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			negotiator := NewMediaNegotiator(WithDefaultQuality(tt.quality))
+			result, err := negotiator.Negotiate(header, priorities, true)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Value)
+		})
+	}
+
+	negotiator := NewMediaNegotiator(WithDefaultQuality(0.5))
+	elements, err := negotiator.GetOrderedElements("text/html, application/json;q=0.8, */*;q=bad")
+	require.NoError(t, err)
+	require.Len(t, elements, 3)
+	assert.Equal(t, "application/json", elements[0].Type)
+	assert.InDelta(t, 0.5, elements[1].Quality, 1e-9)
+	assert.InDelta(t, 0.5, elements[2].Quality, 1e-9, "malformed q falls back to the default")
+
+	// A default of 0 makes unspecified elements unacceptable.
+	_, err = NewMediaNegotiator(WithDefaultQuality(0)).Negotiate("text/html", []string{"text/html"}, false)
+	require.ErrorIs(t, err, ErrNoAcceptableMatch)
+}
+
+func TestWithDefaultQuality_Invalid(t *testing.T) {
+	for _, q := range []float64{-0.1, 1.5, math.NaN()} {
+		assert.PanicsWithError(t, fmt.Sprintf("default quality %v is not within [0, 1]", q), func() {
+			WithDefaultQuality(q)
+		})
+	}
+}
+
+func TestWithPreserveCase(t *testing.T) {
+	header := "Text/HTML;q=0.5, Application/JSON; Charset=UTF-8, *"
+
+	t.Run("negotiation", func(t *testing.T) {
+		negotiator := NewMediaNegotiator(WithPreserveCase(true))
+
+		// The returned type keeps the client's casing, the value stays the priority as given.
+		result, err := negotiator.Negotiate(header, []string{"TEXT/html", "application/Json; charset=utf-8"}, true)
+		require.NoError(t, err)
+		assert.Equal(t, "Application/JSON", result.Type)
+		assert.Equal(t, "application/Json; charset=utf-8", result.Value)
+		assert.Equal(t, "application/json; charset=utf-8", result.NormalizedValue)
+
+		result, err = negotiator.Negotiate(header, []string{"text/html"}, true)
+		require.NoError(t, err)
+		assert.Equal(t, "Text/HTML", result.Type, "lowercase priorities get the client's casing")
+
+		// A priority matched through a range keeps its own casing.
+		result, err = negotiator.Negotiate(header, []string{"Image/PNG"}, true)
+		require.NoError(t, err)
+		assert.Equal(t, "Image/PNG", result.Type)
+
+		match, err := negotiator.NegotiateMatch(header, []string{"text/html"}, true)
+		require.NoError(t, err)
+		assert.Equal(t, "Text/HTML", match.ClientElement.Type)
+	})
+
+	t.Run("elements", func(t *testing.T) {
+		negotiator := NewMediaNegotiator(WithPreserveCase(true))
+
+		elements, err := negotiator.GetOrderedElements(header)
+		require.NoError(t, err)
+		require.Len(t, elements, 3)
+		assert.Equal(t, "Application/JSON", elements[0].Type)
+		assert.Equal(t, "*/*", elements[1].Type, "expanded type is not the given one")
+		assert.Equal(t, "Text/HTML", elements[2].Type)
+		assert.Equal(t, "text/html", elements[2].NormalizedValue)
+
+		var types []string
+		require.NoError(t, negotiator.ParseElements(header, func(h *Header) bool {
+			types = append(types, h.Type)
+
+			return true
+		}))
+		assert.Equal(t, []string{"Text/HTML", "Application/JSON", "*/*"}, types)
+
+		normalized, err := negotiator.Normalize(header)
+		require.NoError(t, err)
+		assert.Equal(t, "application/json; charset=UTF-8, */*, text/html; q=0.5", normalized)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		elements, err := NewMediaNegotiator().GetOrderedElements(header)
+		require.NoError(t, err)
+		assert.Equal(t, "application/json", elements[0].Type)
+	})
+
+	t.Run("aliases", func(t *testing.T) {
+		elements, err := NewCharsetNegotiator(WithPreserveCase(true)).GetOrderedElements("UTF8, ISO-8859-1")
+		require.NoError(t, err)
+		assert.Equal(t, "utf-8", elements[0].Type, "aliases resolve to the canonical name")
+		assert.Equal(t, "ISO-8859-1", elements[1].Type)
+	})
+}
+
+func TestWithMacrolanguageMatching(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         []Option
+		acceptHeader string
+		priorities   []string
+		expectedType string
+		expectedVia  MatchKind
+		expectErr    error
+	}{
+		{
+			name:         "no match without option",
+			acceptHeader: "no",
+			priorities:   []string{"nb"},
+			expectErr:    ErrNoAcceptableMatch,
+		},
+		{
+			name:         "macrolanguage range matches individual language",
+			opts:         []Option{WithMacrolanguageMatching(true)},
+			acceptHeader: "no",
+			priorities:   []string{"nb", "nn"},
+			expectedType: "nb",
+			expectedVia:  MatchMacrolanguage,
+		},
+		{
+			name:         "individual language matches macrolanguage",
+			opts:         []Option{WithMacrolanguageMatching(true)},
+			acceptHeader: "nn-NO",
+			priorities:   []string{"no-NO"},
+			expectedType: "no-no",
+			expectedVia:  MatchMacrolanguage,
+		},
+		{
+			name:         "siblings do not match",
+			opts:         []Option{WithMacrolanguageMatching(true)},
+			acceptHeader: "nn",
+			priorities:   []string{"nb"},
+			expectErr:    ErrNoAcceptableMatch,
+		},
+		{
+			name:         "script must be compatible",
+			opts:         []Option{WithMacrolanguageMatching(true)},
+			acceptHeader: "cmn-Hant",
+			priorities:   []string{"zh-Hans", "zh-Hant"},
+			expectedType: "zh-hant",
+			expectedVia:  MatchMacrolanguage,
+		},
+		{
+			name:         "region must be compatible",
+			opts:         []Option{WithMacrolanguageMatching(true)},
+			acceptHeader: "nb-NO",
+			priorities:   []string{"no-SE"},
+			expectErr:    ErrNoAcceptableMatch,
+		},
+		{
+			name:         "same primary subtag preferred",
+			opts:         []Option{WithMacrolanguageMatching(true)},
+			acceptHeader: "nb",
+			priorities:   []string{"no", "nb"},
+			expectedType: "nb",
+			expectedVia:  MatchExact,
+		},
+		{
+			name:         "base fallback more specific",
+			opts:         []Option{WithMacrolanguageMatching(true), WithLanguageFallback(true)},
+			acceptHeader: "nb-NO;q=0.5, no",
+			priorities:   []string{"nb-SE"},
+			expectedType: "nb-se",
+			expectedVia:  MatchLanguageFallback,
+		},
+		{
+			name:         "macrolanguage more specific than wildcard",
+			opts:         []Option{WithMacrolanguageMatching(true)},
+			acceptHeader: "*;q=0.9, yue;q=0.5",
+			priorities:   []string{"zh-HK"},
+			expectedType: "zh-hk",
+			expectedVia:  MatchMacrolanguage,
+		},
+		{
+			name:         "client quality wins over specificity",
+			opts:         []Option{WithMacrolanguageMatching(true)},
+			acceptHeader: "zh;q=0.5, cmn",
+			priorities:   []string{"zh", "cmn"},
+			expectedType: "cmn",
+			expectedVia:  MatchExact,
+		},
+		{
+			name:         "extended table",
+			opts:         []Option{WithMacrolanguageMatching(true), WithMacrolanguages(map[string]string{"CKB": "ku"})},
+			acceptHeader: "ku",
+			priorities:   []string{"ckb"},
+			expectedType: "ckb",
+			expectedVia:  MatchMacrolanguage,
+		},
+		{
+			name:         "extended table needs option",
+			opts:         []Option{WithMacrolanguages(map[string]string{"ckb": "ku"})},
+			acceptHeader: "ku",
+			priorities:   []string{"ckb"},
+			expectErr:    ErrNoAcceptableMatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NewLanguageNegotiator(tt.opts...).Negotiate(tt.acceptHeader, tt.priorities, true)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedType, result.Type)
+			assert.Equal(t, tt.expectedVia, result.MatchedVia)
+		})
+	}
+}
+
+func TestWithTiebreakDefault(t *testing.T) {
+	priorities := []string{"text/html", "application/xml", "application/json"}
+
+	tests := []struct {
+		name         string
+		opts         []Option
+		acceptHeader string
+		priorities   []string
+		expectedType string
+		expectErr    error
+	}{
+		{
+			name:         "slice order without option",
+			acceptHeader: "*/*",
+			priorities:   priorities,
+			expectedType: "text/html",
+		},
+		{
+			name:         "default wins full wildcard",
+			opts:         []Option{WithTiebreakDefault("application/json")},
+			acceptHeader: "*/*",
+			priorities:   priorities,
+			expectedType: "application/json",
+		},
+		{
+			name:         "default wins equal quality ranges",
+			opts:         []Option{WithTiebreakDefault("application/json")},
+			acceptHeader: "text/html;q=0.8, application/*;q=0.8",
+			priorities:   priorities,
+			expectedType: "application/json",
+		},
+		{
+			name:         "default compared after normalization",
+			opts:         []Option{WithTiebreakDefault("Application/JSON")},
+			acceptHeader: "*/*",
+			priorities:   priorities,
+			expectedType: "application/json",
+		},
+		{
+			name:         "client quality wins over default",
+			opts:         []Option{WithTiebreakDefault("application/json")},
+			acceptHeader: "text/html, */*;q=0.9",
+			priorities:   priorities,
+			expectedType: "text/html",
+		},
+		{
+			name:         "rejected default not chosen",
+			opts:         []Option{WithTiebreakDefault("application/json")},
+			acceptHeader: "*/*, application/json;q=0",
+			priorities:   priorities,
+			expectedType: "text/html",
+		},
+		{
+			name:         "default not among priorities",
+			opts:         []Option{WithTiebreakDefault("application/yaml")},
+			acceptHeader: "*/*",
+			priorities:   priorities,
+			expectedType: "text/html",
+		},
+		{
+			name:         "client preference wins over default",
+			opts:         []Option{WithTiebreakDefault("application/json"), WithClientPreferenceWins(true)},
+			acceptHeader: "text/html, application/json",
+			priorities:   priorities,
+			expectedType: "text/html",
+		},
+		{
+			name:         "no match stays no match",
+			opts:         []Option{WithTiebreakDefault("application/json")},
+			acceptHeader: "image/png",
+			priorities:   priorities,
+			expectErr:    ErrNoAcceptableMatch,
+		},
+		{
+			name:         "empty header default supplies a header instead",
+			opts:         []Option{WithEmptyHeader("application/xml")},
+			acceptHeader: "",
+			priorities:   priorities,
+			expectedType: "application/xml",
+		},
+		{
+			name:         "tie-break default does not supply a header",
+			opts:         []Option{WithTiebreakDefault("application/json")},
+			acceptHeader: "",
+			priorities:   priorities,
+			expectErr:    ErrEmptyHeader,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NewMediaNegotiator(tt.opts...).Negotiate(tt.acceptHeader, tt.priorities, false)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedType, result.Type)
+		})
+	}
+
+	// Weights only break ties the default leaves open.
+	weighted, err := NewMediaNegotiator(WithTiebreakDefault("application/json")).NegotiateWeighted("*/*", []WeightedPriority{
+		{Value: "text/html", Weight: 5},
+		{Value: "application/json", Weight: 1},
+	}, false)
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", weighted.Type)
+}
+
+func TestWithStrictPriorities(t *testing.T) {
+	messyHeader := "text/html;q=high, invalid, application/json;q=0.9"
+
+	tests := []struct {
+		name         string
+		negotiator   *Negotiator
+		header       string
+		priorities   []string
+		strict       bool
+		expectedType string
+		expectErr    error
+	}{
+		{
+			name:         "invalid priority skipped without option",
+			negotiator:   NewMediaNegotiator(),
+			header:       messyHeader,
+			priorities:   []string{"application//json", "application/json"},
+			expectedType: "application/json",
+		},
+		{
+			name:       "invalid priority errors in lenient header mode",
+			negotiator: NewMediaNegotiator(WithStrictPriorities(true)),
+			header:     messyHeader,
+			priorities: []string{"application//json", "application/json"},
+			expectErr:  &InvalidMediaTypeError{},
+		},
+		{
+			name:         "messy header still tolerated",
+			negotiator:   NewMediaNegotiator(WithStrictPriorities(true)),
+			header:       messyHeader,
+			priorities:   []string{"application/json", "text/html"},
+			expectedType: "text/html",
+		},
+		{
+			name:       "messy header rejected in strict mode",
+			negotiator: NewMediaNegotiator(WithStrictPriorities(true)),
+			header:     messyHeader,
+			priorities: []string{"application/json", "text/html"},
+			strict:     true,
+			expectErr:  &InvalidQualityError{},
+		},
+		{
+			name:       "invalid language priority",
+			negotiator: NewLanguageNegotiator(WithStrictPriorities(true)),
+			header:     "en",
+			priorities: []string{"en_US!"},
+			expectErr:  &InvalidLanguageError{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.negotiator.Negotiate(tt.header, tt.priorities, tt.strict)
+			if tt.expectErr != nil {
+				require.Error(t, err)
+				assert.IsType(t, tt.expectErr, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedType, result.Type)
+		})
+	}
+
+	_, err := NewMediaNegotiator(WithStrictPriorities(true)).Negotiate(messyHeader, []string{"application//json"}, false)
+	assert.NotErrorIs(t, err, ErrMalformedHeader)
+}
+
+func TestWithRejectWildcardOnly(t *testing.T) {
+	priorities := []string{"application/json", "text/html"}
+
+	tests := []struct {
+		name         string
+		negotiator   *Negotiator
+		header       string
+		priorities   []string
+		expectedType string
+		expectErr    error
+	}{
+		{"wildcard accepted without option", NewMediaNegotiator(), "*/*", priorities, "application/json", nil},
+		{"wildcard alone", NewMediaNegotiator(WithRejectWildcardOnly(true)), "*/*", priorities, "", ErrWildcardOnly},
+		{"short wildcard", NewMediaNegotiator(WithRejectWildcardOnly(true)), "*", priorities, "", ErrWildcardOnly},
+		{"concrete type with wildcard", NewMediaNegotiator(WithRejectWildcardOnly(true)), "application/json, */*", priorities, "application/json", nil},
+		{"low quality concrete type", NewMediaNegotiator(WithRejectWildcardOnly(true)), "*/*, text/html;q=0.1", priorities, "application/json", nil},
+		{"type range", NewMediaNegotiator(WithRejectWildcardOnly(true)), "text/*", priorities, "text/html", nil},
+		{"rejected concrete type", NewMediaNegotiator(WithRejectWildcardOnly(true)), "*/*, text/html;q=0", priorities, "", ErrWildcardOnly},
+		{"nothing acceptable", NewMediaNegotiator(WithRejectWildcardOnly(true)), "*/*;q=0", priorities, "", ErrNoAcceptableMatch},
+		{"language", NewLanguageNegotiator(WithRejectWildcardOnly(true)), "*", []string{"en"}, "", ErrWildcardOnly},
+		{"language with tag", NewLanguageNegotiator(WithRejectWildcardOnly(true)), "de, *;q=0.5", []string{"en"}, "en", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, strict := range []bool{true, false} {
+				result, err := tt.negotiator.Negotiate(tt.header, tt.priorities, strict)
+				if tt.expectErr != nil {
+					require.ErrorIs(t, err, tt.expectErr)
+
+					continue
+				}
+
+				require.NoError(t, err)
+				assert.Equal(t, tt.expectedType, result.Type)
+			}
+		})
+	}
+
+	_, err := NewMediaNegotiator(WithRejectWildcardOnly(true)).Negotiate("*/*", priorities, false)
+	assert.NotErrorIs(t, err, ErrNoAcceptableMatch)
+}
@@ -3,19 +3,22 @@ package negotiation
 import (
 	"strconv"
 	"strings"
+	"unicode"
 )
 
 // parseAcceptValue parses an accept header value into type, parameters, and quality.
-// Returns the normalized type (lowercase), parameters map (excluding 'q'), and quality value.
-func parseAcceptValue(value string) (typ string, params map[string]string, quality float64, err error) {
+// Returns the normalized type (lowercase), parameters map (excluding 'q'), quality value,
+// and whether the quality was given explicitly by a q parameter. Parameter names
+// must be tokens, so that the normalized value parses back to the same parameters.
+func parseAcceptValue(value string) (typ string, params map[string]string, quality float64, explicit bool, err error) {
 	if value == "" {
-		return "", nil, 1.0, nil
+		return "", nil, 1.0, false, nil
 	}
 
-	parts := strings.Split(value, ";")
+	parts := splitParameters(value)
 	typ = strings.TrimSpace(parts[0])
 	if typ == "" {
-		return "", nil, 0, &InvalidHeaderError{Header: value}
+		return "", nil, 0, false, &InvalidHeaderError{Header: value}
 	}
 
 	params = make(map[string]string)
@@ -33,13 +36,17 @@ func parseAcceptValue(value string) (typ string, params map[string]string, quali
 
 		key, val, _ := strings.Cut(part, "=")
 		key = strings.ToLower(strings.TrimSpace(key))
-		val = strings.Trim(strings.TrimSpace(val), `"`)
+		if !isToken(key) {
+			return "", nil, 0, false, &InvalidHeaderError{Header: value}
+		}
+		val = unquoteValue(strings.TrimSpace(val))
 
-		if key == "q" {
-			quality, err = parseQuality(val)
+		if isQualityParam(key) {
+			quality, err = parseQualityParam(value, val)
 			if err != nil {
-				return "", nil, 0, err
+				return "", nil, 0, false, err
 			}
+			explicit = true
 		} else {
 			params[key] = val
 		}
@@ -47,29 +54,256 @@ func parseAcceptValue(value string) (typ string, params map[string]string, quali
 
 	typ = strings.ToLower(strings.TrimSpace(typ))
 
-	return typ, params, quality, nil
+	return typ, params, quality, explicit, nil
 }
 
-// parseQuality parses and validates a quality value string.
-// Returns a value between 0.0 and 1.0.
-func parseQuality(s string) (float64, error) {
-	q, err := strconv.ParseFloat(s, 64)
-	if err != nil {
-		return 0, err
+// splitParameters splits an accept value on semicolons outside quoted strings,
+// so quoted parameter values may contain ';'.
+func splitParameters(value string) []string {
+	var parts []string
+	start := 0
+	inQuotes := false
+	escaped := false
+
+	for i := 0; i < len(value); i++ {
+		var shouldContinue bool
+		escaped, inQuotes, shouldContinue = processChar(value[i], escaped, inQuotes)
+		if shouldContinue {
+			continue
+		}
+
+		if value[i] == ';' && !inQuotes {
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, value[start:])
+}
+
+// hasEmptyParameter reports whether value contains an empty parameter between
+// semicolons, as in "text/html;;level=1" or "text/html;".
+func hasEmptyParameter(value string) bool {
+	for _, part := range splitParameters(value)[1:] {
+		if strings.TrimSpace(part) == "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// countParameters returns the number of parameters of an accept value, both
+// media range parameters and accept-ext parameters, not counting q or empty ones.
+func countParameters(value string) int {
+	n := 0
+	for _, part := range splitParameters(value)[1:] {
+		name, _, _ := strings.Cut(part, "=")
+		if strings.TrimSpace(part) != "" && !isQualityParam(name) {
+			n++
+		}
+	}
+
+	return n
+}
+
+// hasEmptyElement reports whether header contains an empty list element from a
+// leading, trailing or doubled comma, as in "text/html," or "text/html,,text/plain".
+func hasEmptyElement(header string, commaDecimal bool) bool {
+	prev := 0
+	separators := 0
+	empty := false
+	scanHeader(header, commaDecimal, func(part headerPart) bool {
+		// Elements are separated by exactly one comma, the first by none
+		empty = strings.Count(header[prev:part.start], ",") != separators
+		prev = part.end
+		separators = 1
+
+		return !empty
+	})
+
+	return empty || strings.Contains(header[prev:], ",")
+}
+
+// isQualityParam reports whether a parameter name is the q parameter. The name
+// is matched case-insensitively and ignoring surrounding whitespace, so "Q" and
+// " q " are recognized alike by every negotiator.
+func isQualityParam(name string) bool {
+	return strings.EqualFold(strings.TrimSpace(name), "q")
+}
+
+// stripQuality returns value without its q parameters, keeping everything else as is.
+func stripQuality(value string) string {
+	parts := splitParameters(value)
+	kept := parts[:1]
+	for _, part := range parts[1:] {
+		key, _, _ := strings.Cut(part, "=")
+		if !isQualityParam(key) {
+			kept = append(kept, part)
+		}
 	}
-	if q < 0 {
-		q = 0
-	} else if q > 1 {
-		q = 1
+
+	return strings.Join(kept, ";")
+}
+
+// cutAcceptExtensions splits an accept value at its weight (RFC 7231, section 5.3.2).
+// The returned value keeps the range, its parameters and the weight; the accept-ext
+// parameters following the weight are returned separately. A repeated weight or an
+// extension named like a parameter before the weight is ambiguous and rejected.
+func cutAcceptExtensions(value string) (string, map[string]string, error) {
+	parts := splitParameters(value)
+	names := make(map[string]bool, len(parts))
+
+	for i := 1; i < len(parts); i++ {
+		key, _, _ := strings.Cut(parts[i], "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !isQualityParam(key) {
+			names[key] = true
+
+			continue
+		}
+
+		extensions := make(map[string]string, len(parts)-i-1)
+		for _, part := range parts[i+1:] {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+
+			key, val, _ := strings.Cut(part, "=")
+			key = strings.ToLower(strings.TrimSpace(key))
+			if isQualityParam(key) || names[key] {
+				return "", nil, &InvalidHeaderError{Header: value}
+			}
+			extensions[key] = unquoteValue(strings.TrimSpace(val))
+		}
+
+		return strings.Join(parts[:i+1], ";"), extensions, nil
+	}
+
+	return value, nil, nil
+}
+
+// unquoteValue returns the content of a quoted-string with quoted-pairs resolved
+// (RFC 7230). Unquoted tokens, which may contain characters like '/' and ':',
+// are returned as is; stray quotes of malformed values are trimmed.
+func unquoteValue(val string) string {
+	if len(val) < 2 || val[0] != '"' || val[len(val)-1] != '"' {
+		return strings.Trim(val, `"`)
+	}
+
+	inner := val[1 : len(val)-1]
+	if !strings.Contains(inner, `\`) {
+		return inner
+	}
+
+	var b strings.Builder
+	b.Grow(len(inner))
+	for i := 0; i < len(inner); i++ {
+		if inner[i] == '\\' && i+1 < len(inner) {
+			i++
+		}
+		b.WriteByte(inner[i])
+	}
+
+	return b.String()
+}
+
+// ParseQuality returns the quality of a single header element such as
+// "text/html;level=1;q=0.8", or 1.0 if it has no q parameter, without parsing the
+// rest of the element, e.g. to sort element strings held elsewhere. The q
+// parameter is read as by the negotiators: its name is case-insensitive, the last
+// one applies, and a value that is not an RFC 7231 qvalue (at most three
+// decimals, within [0, 1]) is an InvalidQualityError.
+func ParseQuality(element string) (float64, error) {
+	quality := 1.0
+	for _, part := range splitParameters(element)[1:] {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok || !isQualityParam(key) {
+			continue
+		}
+
+		q, err := parseQualityParam(element, unquoteValue(strings.TrimSpace(val)))
+		if err != nil {
+			return 0, err
+		}
+		quality = q
+	}
+
+	return quality, nil
+}
+
+// parseQualityParam parses the q parameter value of an element, returning an
+// InvalidQualityError naming the element if it is malformed.
+func parseQualityParam(element, value string) (float64, error) {
+	q, err := parseQuality(value)
+	if err != nil {
+		return 0, &InvalidQualityError{Header: element, Quality: value}
 	}
 
 	return q, nil
 }
 
+// parseQuality parses and validates a quality value string against the
+// RFC 7231 qvalue grammar, so exponents, hex floats, signs, infinities and
+// more than three decimals are rejected.
+func parseQuality(s string) (float64, error) {
+	if !isQValue(s) {
+		return 0, &strconv.NumError{Func: "parseQuality", Num: s, Err: strconv.ErrSyntax}
+	}
+
+	return strconv.ParseFloat(s, 64)
+}
+
+// isQValue reports whether s matches qvalue = ( "0" [ "." 0*3DIGIT ] ) / ( "1" [ "." 0*3("0") ] ).
+func isQValue(s string) bool {
+	if len(s) == 0 || len(s) > 5 || (s[0] != '0' && s[0] != '1') {
+		return false
+	}
+	if len(s) == 1 {
+		return true
+	}
+	if s[1] != '.' {
+		return false
+	}
+	for i := 2; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' || (s[0] == '1' && s[i] != '0') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// headerPart is a single element of an Accept* header with its byte offsets
+// in the original header string.
+type headerPart struct {
+	value      string
+	start, end int
+}
+
 // parseHeader parses an Accept* header string into individual accept parts.
 // Handles quoted strings, escaped quotes, and commas correctly using a state machine.
-func parseHeader(header string) ([]string, error) {
-	var parts []string
+// If commaDecimal is set, a comma decimal in a q value ("q=0,8") does not split the header.
+func parseHeader(header string, commaDecimal bool) ([]headerPart, error) {
+	var parts []headerPart
+	scanHeader(header, commaDecimal, func(part headerPart) bool {
+		parts = append(parts, part)
+
+		return true
+	})
+
+	if len(parts) == 0 {
+		return nil, &InvalidHeaderError{Header: header}
+	}
+
+	return parts, nil
+}
+
+// scanHeader splits an Accept* header into its non-empty parts in a single pass,
+// calling yield for each part in header order until yield returns false.
+// If commaDecimal is set, a comma decimal in a q value does not split the header.
+func scanHeader(header string, commaDecimal bool, yield func(headerPart) bool) {
 	start := 0
 	inQuotes := false
 	escaped := false
@@ -82,21 +316,116 @@ func parseHeader(header string) ([]string, error) {
 			continue
 		}
 
-		if c == ',' && !inQuotes {
-			if part := extractPart(header[start:i]); part != "" {
-				parts = append(parts, part)
+		if c == ',' && !inQuotes && !(commaDecimal && isCommaDecimal(header, i)) {
+			if part, ok := extractPart(header, start, i); ok && !yield(part) {
+				return
 			}
 			start = i + 1
 		}
 	}
 
-	parts = appendFinalPart(parts, header, start)
+	if start < len(header) {
+		if part, ok := extractPart(header, start, len(header)); ok {
+			yield(part)
+		}
+	}
+}
 
-	if len(parts) == 0 {
-		return nil, &InvalidHeaderError{Header: header}
+// isCommaDecimal reports whether the comma at header[i] is the decimal separator
+// of a q value, as in "text/html;q=0,8" sent by clients with a comma-decimal
+// locale: it directly follows "q=" and a digit and is directly followed by a digit.
+func isCommaDecimal(header string, i int) bool {
+	if i < 1 || i+1 >= len(header) || !isDigit(header[i-1:i]) || !isDigit(header[i+1:i+2]) {
+		return false
 	}
 
-	return parts, nil
+	before, ok := strings.CutSuffix(strings.TrimRight(header[:i-1], " \t"), "=")
+	if !ok {
+		return false
+	}
+
+	before = strings.TrimRight(before, " \t")
+	if before == "" || !isQualityParam(before[len(before)-1:]) {
+		return false
+	}
+
+	return strings.HasSuffix(strings.TrimRight(before[:len(before)-1], " \t"), ";")
+}
+
+// dotDecimalQuality returns value with a comma decimal separator in its q
+// parameters replaced by a dot, keeping everything else as is.
+func dotDecimalQuality(value string) string {
+	parts := splitParameters(value)
+	for i, part := range parts[1:] {
+		key, val, _ := strings.Cut(part, "=")
+		if isQualityParam(key) {
+			parts[i+1] = key + "=" + strings.Replace(val, ",", ".", 1)
+		}
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// clampQuality returns value with each q parameter holding a plain decimal
+// outside the qvalue grammar, such as "-1", "1.5" or "0.0001", replaced by the
+// nearest qvalue: negative values become 0, values above 1 become 1 and decimals
+// past the third are truncated. It reports false if a q value is not a plain
+// decimal, e.g. "abc" or "1e-1".
+func clampQuality(value string) (string, bool) {
+	parts := splitParameters(value)
+	for i, part := range parts[1:] {
+		key, val, _ := strings.Cut(part, "=")
+		if !isQualityParam(key) {
+			continue
+		}
+
+		q, ok := clampQualityValue(unquoteValue(strings.TrimSpace(val)))
+		if !ok {
+			return "", false
+		}
+		parts[i+1] = key + "=" + q
+	}
+
+	return strings.Join(parts, ";"), true
+}
+
+// clampQualityValue returns the qvalue nearest to the plain decimal s, or false
+// if s is not one.
+func clampQualityValue(s string) (string, bool) {
+	digits := s
+	if s != "" && (s[0] == '+' || s[0] == '-') {
+		digits = s[1:]
+	}
+	whole, frac, _ := strings.Cut(digits, ".")
+	if whole+frac == "" || !isDigit(whole) || !isDigit(frac) {
+		return "", false
+	}
+
+	switch {
+	case s[0] == '-' || strings.Trim(whole+frac, "0") == "":
+		return "0", true
+	case strings.TrimLeft(whole, "0") != "":
+		return "1", true
+	}
+	if len(frac) > 3 {
+		frac = frac[:3]
+	}
+
+	return "0." + frac, true
+}
+
+// validateFieldValue checks that a header value contains no control characters.
+// Per RFC 7230 only spaces and horizontal tabs are allowed as whitespace, so bare
+// CR/LF and obsolete line folding (obs-fold) are rejected.
+func validateFieldValue(header string) error {
+	for i := 0; i < len(header); i++ {
+		c := header[i]
+		if (c < ' ' && c != '\t') || c == 0x7f {
+			return &InvalidHeaderError{Header: header}
+		}
+	}
+
+	return nil
 }
 
 // processChar processes a single character in the state machine.
@@ -117,20 +446,16 @@ func processChar(c byte, escaped, inQuotes bool) (newEscaped, newInQuotes, shoul
 	return false, inQuotes, false
 }
 
-// extractPart extracts and trims a part from the header string.
-func extractPart(s string) string {
-	return strings.TrimSpace(s)
-}
-
-// appendFinalPart appends the final part if it exists and is non-empty.
-func appendFinalPart(parts []string, header string, start int) []string {
-	if start >= len(header) {
-		return parts
+// extractPart extracts and trims the part header[start:end], adjusting its
+// offsets to the trimmed value. Reports false if the part is empty.
+func extractPart(header string, start, end int) (headerPart, bool) {
+	s := header[start:end]
+	value := strings.TrimSpace(s)
+	if value == "" {
+		return headerPart{}, false
 	}
 
-	if part := extractPart(header[start:]); part != "" {
-		parts = append(parts, part)
-	}
+	start += len(s) - len(strings.TrimLeftFunc(s, unicode.IsSpace))
 
-	return parts
+	return headerPart{value: value, start: start, end: start + len(value)}, true
 }
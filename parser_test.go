@@ -1,6 +1,7 @@
 package negotiation
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -9,12 +10,13 @@ import (
 
 func TestParseAcceptValue(t *testing.T) {
 	tests := []struct {
-		name           string
-		value          string
-		expectedType   string
-		expectedParams map[string]string
-		expectedQ      float64
-		expectErr      bool
+		name             string
+		value            string
+		expectedType     string
+		expectedParams   map[string]string
+		expectedQ        float64
+		expectedExplicit bool
+		expectErr        bool
 	}{
 		{
 			name:         "empty value",
@@ -29,10 +31,11 @@ func TestParseAcceptValue(t *testing.T) {
 			expectedQ:    1.0,
 		},
 		{
-			name:         "with quality",
-			value:        "text/html;q=0.8",
-			expectedType: "text/html",
-			expectedQ:    0.8,
+			name:             "with quality",
+			value:            "text/html;q=0.8",
+			expectedType:     "text/html",
+			expectedQ:        0.8,
+			expectedExplicit: true,
 		},
 		{
 			name:         "with parameters",
@@ -52,7 +55,27 @@ func TestParseAcceptValue(t *testing.T) {
 				"charset": "UTF-8",
 				"level":   "2",
 			},
-			expectedQ: 0.7,
+			expectedQ:        0.7,
+			expectedExplicit: true,
+		},
+		{
+			name:         "whitespace around parameter value",
+			value:        "text/html; level = 2 \t; charset= utf-8",
+			expectedType: "text/html",
+			expectedParams: map[string]string{
+				"level":   "2",
+				"charset": "utf-8",
+			},
+			expectedQ: 1.0,
+		},
+		{
+			name:         "whitespace inside quoted parameter value",
+			value:        "text/html; foo=\" bar \"",
+			expectedType: "text/html",
+			expectedParams: map[string]string{
+				"foo": " bar ",
+			},
+			expectedQ: 1.0,
 		},
 		{
 			name:         "quoted parameter",
@@ -63,11 +86,74 @@ func TestParseAcceptValue(t *testing.T) {
 			},
 			expectedQ: 1.0,
 		},
+		{
+			name:         "unquoted URL parameter",
+			value:        "application/json;profile=https://example.com/schema",
+			expectedType: "application/json",
+			expectedParams: map[string]string{
+				"profile": "https://example.com/schema",
+			},
+			expectedQ: 1.0,
+		},
+		{
+			name:         "quoted URL parameter",
+			value:        `application/json;profile="https://example.com/s"`,
+			expectedType: "application/json",
+			expectedParams: map[string]string{
+				"profile": "https://example.com/s",
+			},
+			expectedQ: 1.0,
+		},
+		{
+			name:         "semicolon inside quotes",
+			value:        `text/html; title="a;b"; q=0.5`,
+			expectedType: "text/html",
+			expectedParams: map[string]string{
+				"title": "a;b",
+			},
+			expectedQ:        0.5,
+			expectedExplicit: true,
+		},
+		{
+			name:         "escaped quotes inside quotes",
+			value:        `text/html; profile="\"http://example.com/profile\""`,
+			expectedType: "text/html",
+			expectedParams: map[string]string{
+				"profile": `"http://example.com/profile"`,
+			},
+			expectedQ: 1.0,
+		},
+		{
+			name:             "explicit default quality",
+			value:            "text/html;q=1",
+			expectedType:     "text/html",
+			expectedQ:        1.0,
+			expectedExplicit: true,
+		},
 		{
 			name:      "empty type",
 			value:     ";q=0.8",
 			expectErr: true,
 		},
+		{
+			name:         "parameter value case preserved",
+			value:        "Multipart/Form-Data; Boundary=AaBb--XyZ",
+			expectedType: "multipart/form-data",
+			expectedParams: map[string]string{
+				"boundary": "AaBb--XyZ",
+			},
+			expectedQ: 1.0,
+		},
+		{
+			name:      "invalid parameter name",
+			value:     "text/html; \"a\"=b",
+			expectErr: true,
+		},
+		{
+			name:      "empty parameter name",
+			value:     "text/html; =b",
+			expectErr: true,
+		},
 		{
 			name:         "with spaces",
 			value:        "text/html ; q = 0.8 ; charset = UTF-8",
@@ -75,13 +161,14 @@ func TestParseAcceptValue(t *testing.T) {
 			expectedParams: map[string]string{
 				"charset": "UTF-8",
 			},
-			expectedQ: 0.8,
+			expectedQ:        0.8,
+			expectedExplicit: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			typ, params, q, err := parseAcceptValue(tt.value)
+			typ, params, q, explicit, err := parseAcceptValue(tt.value)
 
 			if tt.expectErr {
 				require.Error(t, err)
@@ -92,6 +179,7 @@ func TestParseAcceptValue(t *testing.T) {
 			require.NoError(t, err)
 			assert.Equal(t, tt.expectedType, typ)
 			assert.Equal(t, tt.expectedQ, q)
+			assert.Equal(t, tt.expectedExplicit, explicit)
 
 			if tt.expectedParams != nil {
 				for k, v := range tt.expectedParams {
@@ -112,9 +200,21 @@ func TestParseQuality(t *testing.T) {
 		{"valid", "0.8", 0.8, false},
 		{"valid 1.0", "1.0", 1.0, false},
 		{"valid 0.0", "0.0", 0.0, false},
-		{"clamped above 1", "1.5", 1.0, false},
-		{"clamped below 0", "-0.5", 0.0, false},
-		{"invalid", "abc", 0.0, true},
+		{"valid 0", "0", 0.0, false},
+		{"valid 1", "1", 1.0, false},
+		{"three decimals", "0.125", 0.125, false},
+		{"trailing dot", "0.", 0.0, false},
+		{"above 1", "1.5", 0, true},
+		{"1 with non-zero decimal", "1.001", 0, true},
+		{"negative", "-0.5", 0, true},
+		{"sign", "+.5", 0, true},
+		{"leading dot", ".5", 0, true},
+		{"more than three decimals", "0.12345", 0, true},
+		{"exponent", "1e-1", 0, true},
+		{"hex float", "0x1p-1", 0, true},
+		{"invalid", "abc", 0, true},
+		{"infinity", "Inf", 0, true},
+		{"NaN", "NaN", 0, true},
 	}
 
 	for _, tt := range tests {
@@ -131,6 +231,54 @@ func TestParseQuality(t *testing.T) {
 	}
 }
 
+func TestParseQualityOfElement(t *testing.T) {
+	tests := []struct {
+		name      string
+		element   string
+		expected  float64
+		expectErr bool
+	}{
+		{"no q", "text/html;level=1", 1.0, false},
+		{"bare type", "text/html", 1.0, false},
+		{"q", "text/html;q=0.8", 0.8, false},
+		{"q among parameters", "text/html; level=1; q=0.5; ext=a", 0.5, false},
+		{"uppercase and spaces", "text/html; Q = 0.3", 0.3, false},
+		{"quoted", `text/html;q="0.7"`, 0.7, false},
+		{"last q applies", "text/html;q=0.2;q=0.4", 0.4, false},
+		{"q inside quoted value", `text/html;foo="a;q=0.1"`, 1.0, false},
+		{"three decimals", "text/html;q=0.001", 0.001, false},
+		{"above 1", "text/html;q=2", 0, true},
+		{"negative", "text/html;q=-1", 0, true},
+		{"more than three decimals", "text/html;q=0.0001", 0, true},
+		{"exponent", "text/html;q=1e-1", 0, true},
+		{"hex float", "text/html;q=0x1p-1", 0, true},
+		{"malformed", "text/html;q=high", 0, true},
+		{"empty", "text/html;q=", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := ParseQuality(tt.element)
+			if tt.expectErr {
+				var qualityErr *InvalidQualityError
+				require.ErrorAs(t, err, &qualityErr)
+				assert.Equal(t, tt.element, qualityErr.Header)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, q)
+
+			// The negotiators read the same quality
+			elements, err := NewMediaNegotiator().GetOrderedElements(tt.element)
+			require.NoError(t, err)
+			require.Len(t, elements, 1)
+			assert.Equal(t, q, elements[0].Quality)
+		})
+	}
+}
+
 func TestBuildNormalizedValue(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -156,6 +304,30 @@ func TestBuildNormalizedValue(t *testing.T) {
 			params:   map[string]string{"charset": "UTF-8"},
 			expected: "text/html; charset=UTF-8",
 		},
+		{
+			name:     "value with space",
+			typ:      "text/html",
+			params:   map[string]string{"title": "a b"},
+			expected: `text/html; title="a b"`,
+		},
+		{
+			name:     "value with separators",
+			typ:      "text/html",
+			params:   map[string]string{"profile": "http://example.com/a;b,c"},
+			expected: `text/html; profile="http://example.com/a;b,c"`,
+		},
+		{
+			name:     "value with quote and backslash",
+			typ:      "text/html",
+			params:   map[string]string{"foo": `say "hi" \o/`},
+			expected: `text/html; foo="say \"hi\" \\o/"`,
+		},
+		{
+			name:     "empty value",
+			typ:      "text/html",
+			params:   map[string]string{"foo": ""},
+			expected: `text/html; foo=""`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -166,6 +338,34 @@ func TestBuildNormalizedValue(t *testing.T) {
 	}
 }
 
+func TestNormalizedValue_RoundTrip(t *testing.T) {
+	values := []string{
+		"text/html; z=y; a=b",
+		`text/html; title="a b"`,
+		`text/html; foo="bar,baz"; q=0.5`,
+		`text/html; foo="a;b"`,
+		`text/html; profile="\"http://example.com/profile\""`,
+		`text/html; foo="back\\slash"`,
+		`text/html; foo=""`,
+		`text/html; foo=" padded "`,
+		"text/html; url=http://example.com/a",
+		"TEXT/HTML; Level=2",
+	}
+
+	for _, value := range values {
+		t.Run(value, func(t *testing.T) {
+			original, err := newMedia(value)
+			require.NoError(t, err)
+
+			reparsed, err := newMedia(original.NormalizedValue)
+			require.NoError(t, err)
+			assert.Equal(t, original.Type, reparsed.Type)
+			assert.Equal(t, original.Parameters, reparsed.Parameters)
+			assert.Equal(t, original.NormalizedValue, reparsed.NormalizedValue)
+		})
+	}
+}
+
 func TestParseHeader(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -217,7 +417,7 @@ func TestParseHeader(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result, err := parseHeader(tt.header)
+			result, err := parseHeader(tt.header, false)
 
 			if tt.expectErr {
 				require.Error(t, err)
@@ -226,7 +426,174 @@ func TestParseHeader(t *testing.T) {
 			}
 
 			require.NoError(t, err)
-			assert.Equal(t, tt.expected, result)
+			values := make([]string, 0, len(result))
+			for _, part := range result {
+				assert.Equal(t, part.value, tt.header[part.start:part.end])
+				values = append(values, part.value)
+			}
+			assert.Equal(t, tt.expected, values)
 		})
 	}
 }
+
+func TestValidateFieldValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		expectErr bool
+	}{
+		{"plain", "text/html, application/json;q=0.9", false},
+		{"spaces around separators", "text/html ; level = 2 ; q = 0.4", false},
+		{"tabs around separators", "text/html\t;\tlevel=2", false},
+		{"bare LF", "text/html\nSet-Cookie: a=b", true},
+		{"bare CR", "text/html\r, application/json", true},
+		{"obsolete line folding", "text/html,\r\n application/json", true},
+		{"NUL", "text/html\x00", true},
+		{"DEL", "text/html\x7f", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateFieldValue(tt.header)
+			if tt.expectErr {
+				require.Error(t, err)
+				assert.IsType(t, &InvalidHeaderError{}, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestCountParameters(t *testing.T) {
+	assert.Equal(t, 0, countParameters("text/html"))
+	assert.Equal(t, 0, countParameters("text/html;q=0.5"))
+	assert.Equal(t, 2, countParameters("text/html; level=1; Q=0.5; ext=a"))
+	assert.Equal(t, 1, countParameters("text/html;;level=1;"))
+	assert.Equal(t, 1, countParameters(`text/html; foo="a;b=c"`))
+}
+
+func TestHasEmptyElement(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		expected bool
+	}{
+		{"single", "application/json", false},
+		{"list", "application/json, text/html ,text/plain", false},
+		{"comma inside quotes", `text/html; foo="a,,b", application/json`, false},
+		{"trailing comma", "application/json,", true},
+		{"trailing comma and space", "application/json, ", true},
+		{"leading comma", ",application/json", true},
+		{"doubled comma", "application/json,,text/html", true},
+		{"spaced doubled comma", "application/json, ,text/html", true},
+		{"only commas", ", ,", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, hasEmptyElement(tt.header, false))
+		})
+	}
+}
+
+func TestStripQuality(t *testing.T) {
+	assert.Equal(t, "text/html;level=1", stripQuality("text/html;q=0.5x;level=1"))
+	assert.Equal(t, "text/html; foo=\"q=1;\"", stripQuality("text/html; Q = abc; foo=\"q=1;\""))
+	assert.Equal(t, "text/html", stripQuality("text/html"))
+}
+
+func TestIsQualityParam(t *testing.T) {
+	for _, name := range []string{"q", "Q", " q", "Q\t", "  Q  "} {
+		assert.True(t, isQualityParam(name), "%q", name)
+	}
+	for _, name := range []string{"", "qs", "level", "q q", "quality"} {
+		assert.False(t, isQualityParam(name), "%q", name)
+	}
+}
+
+func TestCutAcceptExtensions(t *testing.T) {
+	tests := []struct {
+		name               string
+		value              string
+		expectedValue      string
+		expectedExtensions map[string]string
+		expectErr          bool
+	}{
+		{"no weight", "text/html;level=1", "text/html;level=1", nil, false},
+		{"weight last", "text/html;level=1;q=0.5", "text/html;level=1;q=0.5", map[string]string{}, false},
+		{"extensions", "text/html;q=0.5; Foo=\"a;b\" ;bar", "text/html;q=0.5", map[string]string{"foo": "a;b", "bar": ""}, false},
+		{"uppercase weight", "text/html; Q=0.5;level=1", "text/html; Q=0.5", map[string]string{"level": "1"}, false},
+		{"repeated weight", "text/html;q=0.5;q=1", "", nil, true},
+		{"extension shadows parameter", "text/html;level=1;q=0.5;LEVEL=2", "", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, extensions, err := cutAcceptExtensions(tt.value)
+			if tt.expectErr {
+				require.Error(t, err)
+				assert.IsType(t, &InvalidHeaderError{}, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedValue, value)
+			assert.Equal(t, tt.expectedExtensions, extensions)
+		})
+	}
+}
+
+func TestUnquoteValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{"token", "utf-8", "utf-8"},
+		{"token with special characters", "https://example.com/s?a=b", "https://example.com/s?a=b"},
+		{"quoted string", `"a b"`, "a b"},
+		{"quoted pair", `"a \"b\" c"`, `a "b" c`},
+		{"escaped backslash", `"a\\b"`, `a\b`},
+		{"empty quoted string", `""`, ""},
+		{"unclosed quote", `"unclosed`, "unclosed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, unquoteValue(tt.value))
+		})
+	}
+}
+
+// longQuotedHeader returns a single-element header whose quoted parameter value is
+// about size bytes long and contains separators and quoted-pairs.
+func longQuotedHeader(size int) string {
+	return `text/html;x="` + strings.Repeat(`a,b;c\"`, size/7) + `";q=0.5`
+}
+
+func TestGetOrderedElements_LongQuotedValue(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	elements, err := negotiator.GetOrderedElements(longQuotedHeader(64 << 10))
+	require.NoError(t, err)
+	require.Len(t, elements, 1)
+	assert.Equal(t, strings.Repeat(`a,b;c"`, (64<<10)/7), elements[0].Parameters["x"])
+	assert.InDelta(t, 0.5, elements[0].Quality, 0.0001)
+}
+
+func BenchmarkGetOrderedElements_LongQuotedValue(b *testing.B) {
+	negotiator := NewMediaNegotiator()
+	header := longQuotedHeader(64 << 10)
+
+	b.SetBytes(int64(len(header)))
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := negotiator.GetOrderedElements(header); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
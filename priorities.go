@@ -0,0 +1,25 @@
+package negotiation
+
+import "strings"
+
+// MergePriorities returns base followed by override, keeping only the first
+// occurrence of each priority. Priorities are compared after trimming
+// surrounding whitespace. Since earlier priorities win ties in Negotiate, a
+// priority present in both lists keeps its position from base.
+func MergePriorities(base, override []string) []string {
+	merged := make([]string, 0, len(base)+len(override))
+	seen := make(map[string]struct{}, len(base)+len(override))
+
+	for _, list := range [][]string{base, override} {
+		for _, p := range list {
+			key := strings.TrimSpace(p)
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			merged = append(merged, p)
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,78 @@
+package negotiation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergePriorities(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     []string
+		override []string
+		expected []string
+	}{
+		{
+			name:     "concatenates in order",
+			base:     []string{"application/json", "text/html"},
+			override: []string{"application/xml"},
+			expected: []string{"application/json", "text/html", "application/xml"},
+		},
+		{
+			name:     "keeps first occurrence",
+			base:     []string{"application/json", "text/html"},
+			override: []string{"text/html", "application/xml", "application/json"},
+			expected: []string{"application/json", "text/html", "application/xml"},
+		},
+		{
+			name:     "removes duplicates within a list",
+			base:     []string{"text/html", "text/html"},
+			override: []string{"application/xml", "application/xml"},
+			expected: []string{"text/html", "application/xml"},
+		},
+		{
+			name:     "ignores surrounding whitespace",
+			base:     []string{"text/html"},
+			override: []string{" text/html "},
+			expected: []string{"text/html"},
+		},
+		{
+			name:     "empty base",
+			override: []string{"text/html"},
+			expected: []string{"text/html"},
+		},
+		{
+			name:     "both empty",
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, MergePriorities(tt.base, tt.override))
+		})
+	}
+}
+
+func TestMergePriorities_DoesNotModifyInputs(t *testing.T) {
+	base := []string{"text/html", "application/json"}
+	override := []string{"application/json", "application/xml"}
+
+	MergePriorities(base, override)
+
+	assert.Equal(t, []string{"text/html", "application/json"}, base)
+	assert.Equal(t, []string{"application/json", "application/xml"}, override)
+}
+
+func TestMergePriorities_Negotiate(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	// The base order decides ties between equally acceptable priorities.
+	priorities := MergePriorities([]string{"application/json"}, []string{"text/html", "application/json"})
+
+	result, err := negotiator.Negotiate("text/html, application/json", priorities, true)
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", result.Value)
+}
@@ -0,0 +1,75 @@
+package negotiation
+
+// RegisteredType is a type registered in a TypeRegistry together with its metadata.
+type RegisteredType[T any] struct {
+	// Type is the registered priority string, e.g. "application/json".
+	Type string
+	// Value is the metadata registered with the type, e.g. a renderer.
+	Value T
+	// Weight is the server preference used to break ties between equally acceptable types.
+	Weight float64
+}
+
+// TypeRegistry holds a set of supported types with metadata and negotiates among them.
+// The priority list is derived from registration order. A TypeRegistry must not be
+// modified concurrently with negotiation.
+type TypeRegistry[T any] struct {
+	negotiator *Negotiator
+	types      []*RegisteredType[T]
+	byType     map[string]*RegisteredType[T]
+}
+
+// NewTypeRegistry creates a new TypeRegistry using the given negotiator.
+// A nil negotiator defaults to a media type negotiator.
+func NewTypeRegistry[T any](negotiator *Negotiator) *TypeRegistry[T] {
+	if negotiator == nil {
+		negotiator = NewMediaNegotiator()
+	}
+
+	return &TypeRegistry[T]{
+		negotiator: negotiator,
+		byType:     make(map[string]*RegisteredType[T]),
+	}
+}
+
+// Register registers typ with its metadata and the default weight of 1.
+func (r *TypeRegistry[T]) Register(typ string, value T) {
+	r.RegisterWeighted(typ, value, 1.0)
+}
+
+// RegisterWeighted registers typ with its metadata and a server preference weight.
+// Registering the same type again replaces its metadata and weight but keeps its position.
+func (r *TypeRegistry[T]) RegisterWeighted(typ string, value T, weight float64) {
+	if existing, ok := r.byType[typ]; ok {
+		existing.Value = value
+		existing.Weight = weight
+
+		return
+	}
+
+	entry := &RegisteredType[T]{Type: typ, Value: value, Weight: weight}
+	r.types = append(r.types, entry)
+	r.byType[typ] = entry
+}
+
+// Negotiate returns the registered type that best matches the header. A wildcard
+// type such as "*/*" or "application/*" is returned for the client types it covers.
+// A type the negotiator adds on its own, such as the implicit identity coding
+// (see WithImplicitIdentity), is not registered and gives ErrNoAcceptableMatch.
+func (r *TypeRegistry[T]) Negotiate(header string, strict bool) (*RegisteredType[T], error) {
+	priorities := make([]WeightedPriority, len(r.types))
+	for i, t := range r.types {
+		priorities[i] = WeightedPriority{Value: t.Type, Weight: t.Weight}
+	}
+
+	best, err := r.negotiator.NegotiateWeighted(header, priorities, strict)
+	if err != nil {
+		return nil, err
+	}
+
+	if best.priorityIndex < 0 {
+		return nil, ErrNoAcceptableMatch
+	}
+
+	return r.types[best.priorityIndex], nil
+}
@@ -0,0 +1,124 @@
+package negotiation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypeRegistry_Negotiate(t *testing.T) {
+	registry := NewTypeRegistry[string](nil)
+	registry.Register("application/json", "json")
+	registry.Register("text/html", "html")
+
+	tests := []struct {
+		name         string
+		acceptHeader string
+		expected     string
+		expectErr    error
+	}{
+		{"exact match", "text/html", "html", nil},
+		{"quality preference", "text/html;q=0.5, application/json", "json", nil},
+		{"registration order breaks ties", "*/*", "json", nil},
+		{"no acceptable match", "image/png", "", ErrNoAcceptableMatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := registry.Negotiate(tt.acceptHeader, false)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+				assert.Nil(t, result)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result.Value)
+		})
+	}
+}
+
+func TestTypeRegistry_WildcardType(t *testing.T) {
+	registry := NewTypeRegistry[string](nil)
+	registry.Register("application/json", "json")
+	registry.Register("*/*", "passthrough")
+
+	result, err := registry.Negotiate("text/html", false)
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, "*/*", result.Type)
+	assert.Equal(t, "passthrough", result.Value)
+
+	result, err = registry.Negotiate("application/json, text/html", false)
+	require.NoError(t, err)
+	assert.Equal(t, "json", result.Value)
+}
+
+func TestTypeRegistry_TypeWildcard(t *testing.T) {
+	registry := NewTypeRegistry[string](nil)
+	registry.Register("text/html", "html")
+	registry.Register("application/*", "binary")
+
+	result, err := registry.Negotiate("application/cbor", false)
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, "application/*", result.Type)
+	assert.Equal(t, "binary", result.Value)
+
+	_, err = registry.Negotiate("image/png", false)
+	require.ErrorIs(t, err, ErrNoAcceptableMatch)
+}
+
+func TestTypeRegistry_ImplicitIdentity(t *testing.T) {
+	registry := NewTypeRegistry[string](NewEncodingNegotiator(WithImplicitIdentity(true)))
+	registry.Register("gzip", "gzip writer")
+
+	// The implicit identity coding is not registered.
+	result, err := registry.Negotiate("br", false)
+	require.ErrorIs(t, err, ErrNoAcceptableMatch)
+	assert.Nil(t, result)
+
+	registry.Register("identity", "plain writer")
+	result, err = registry.Negotiate("br", false)
+	require.NoError(t, err)
+	require.NotNil(t, result)
+	assert.Equal(t, "plain writer", result.Value)
+}
+
+func TestTypeRegistry_Weights(t *testing.T) {
+	registry := NewTypeRegistry[int](nil)
+	registry.Register("application/json", 1)
+	registry.RegisterWeighted("application/cbor", 2, 2.0)
+
+	// Weight breaks ties between equally acceptable types.
+	result, err := registry.Negotiate("application/*", false)
+	require.NoError(t, err)
+	assert.Equal(t, "application/cbor", result.Type)
+	assert.Equal(t, 2.0, result.Weight)
+
+	// Weight never overrides client quality.
+	result, err = registry.Negotiate("application/json, application/cbor;q=0.9", false)
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", result.Type)
+}
+
+func TestTypeRegistry_RegisterReplaces(t *testing.T) {
+	registry := NewTypeRegistry[string](NewLanguageNegotiator())
+	registry.Register("en", "english")
+	registry.Register("fr", "french")
+	registry.Register("en", "english v2")
+
+	result, err := registry.Negotiate("*", false)
+	require.NoError(t, err)
+	assert.Equal(t, "en", result.Type)
+	assert.Equal(t, "english v2", result.Value)
+}
+
+func TestTypeRegistry_Empty(t *testing.T) {
+	registry := NewTypeRegistry[string](nil)
+
+	_, err := registry.Negotiate("text/html", false)
+	require.ErrorIs(t, err, ErrEmptyPriorities)
+}
@@ -0,0 +1,61 @@
+package negotiation
+
+// Trace records how a negotiation reached its decision.
+type Trace struct {
+	// Priorities holds one entry per successfully parsed priority, in priority order.
+	Priorities []PriorityTrace
+}
+
+// PriorityTrace records how a single priority was evaluated.
+type PriorityTrace struct {
+	// Priority is the parsed server priority.
+	Priority *Header
+	// Element is the most specific client element matching the priority, nil if none matched.
+	Element *Header
+	// Quality is the resolved quality; 0 if the priority was rejected or unmatched.
+	Quality float64
+	// Score is the specificity score of the match; higher is more specific.
+	Score int
+	// Selected reports whether the priority won the negotiation.
+	Selected bool
+}
+
+// NegotiateWithTrace behaves like Negotiate but also returns a Trace explaining the decision.
+// The trace is returned together with ErrNoAcceptableMatch so unexpected 406s can be
+// debugged; it is nil for other errors. Negotiate does not pay for tracing.
+func (c *Negotiator) NegotiateWithTrace(header string, priorities []string, strict bool) (*Header, *Trace, error) {
+	n, err := c.negotiate(header, unweighted(priorities), strict, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.observe(n)
+	trace := newTrace(n)
+	if n.best == nil {
+		return nil, trace, ErrNoAcceptableMatch
+	}
+
+	return n.bestHeader(), trace, nil
+}
+
+// newTrace builds a Trace from the intermediate results of a negotiation.
+func newTrace(n *negotiation) *Trace {
+	byIndex := make(map[int]*matchResult, len(n.matches))
+	for _, match := range n.matches {
+		byIndex[match.Index] = match
+	}
+
+	trace := &Trace{Priorities: make([]PriorityTrace, len(n.priorities))}
+	for i, priority := range n.priorities {
+		entry := PriorityTrace{Priority: priority}
+		if match, ok := byIndex[i]; ok {
+			entry.Element = match.Accept
+			entry.Quality = match.Quality
+			entry.Score = match.Score
+			entry.Selected = match == n.best
+		}
+		trace.Priorities[i] = entry
+	}
+
+	return trace
+}
@@ -0,0 +1,66 @@
+package negotiation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiator_NegotiateWithTrace(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	best, trace, err := negotiator.NegotiateWithTrace(
+		"text/*;q=0.5, text/html;q=0.8, image/png;q=0",
+		[]string{"text/plain", "text/html", "image/png", "application/json"},
+		false,
+	)
+	require.NoError(t, err)
+	require.NotNil(t, trace)
+	assert.Equal(t, "text/html", best.Type)
+	require.Len(t, trace.Priorities, 4)
+
+	plain := trace.Priorities[0]
+	assert.Equal(t, "text/plain", plain.Priority.Type)
+	assert.Equal(t, "text/*", plain.Element.Type)
+	assert.Equal(t, 0.5, plain.Quality)
+	assert.Equal(t, 100, plain.Score)
+	assert.False(t, plain.Selected)
+
+	html := trace.Priorities[1]
+	assert.Equal(t, "text/html", html.Element.Type)
+	assert.Equal(t, 0.8, html.Quality)
+	assert.Equal(t, 110, html.Score)
+	assert.True(t, html.Selected)
+
+	png := trace.Priorities[2]
+	assert.Equal(t, "image/png", png.Element.Type)
+	assert.Equal(t, 0.0, png.Quality)
+	assert.False(t, png.Selected)
+
+	json := trace.Priorities[3]
+	assert.Nil(t, json.Element)
+	assert.Equal(t, 0.0, json.Quality)
+	assert.False(t, json.Selected)
+}
+
+func TestNegotiator_NegotiateWithTrace_NoAcceptableMatch(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	best, trace, err := negotiator.NegotiateWithTrace("text/html;q=0", []string{"text/html"}, false)
+	require.ErrorIs(t, err, ErrNoAcceptableMatch)
+	assert.Nil(t, best)
+	require.NotNil(t, trace)
+	require.Len(t, trace.Priorities, 1)
+	assert.Equal(t, "text/html", trace.Priorities[0].Element.Type)
+	assert.False(t, trace.Priorities[0].Selected)
+}
+
+func TestNegotiator_NegotiateWithTrace_InvalidArguments(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	best, trace, err := negotiator.NegotiateWithTrace("text/html", nil, false)
+	require.ErrorIs(t, err, ErrEmptyPriorities)
+	assert.Nil(t, best)
+	assert.Nil(t, trace)
+}
@@ -0,0 +1,31 @@
+package negotiation
+
+import (
+	"maps"
+	"slices"
+)
+
+// NegotiateTyped negotiates the header against the keys of options and returns
+// the value associated with the winning priority, or with the wildcard key it
+// was resolved from. A priority the negotiator adds on its own, such as the
+// implicit identity coding (see WithImplicitIdentity), is not among the keys
+// and gives ErrNoAcceptableMatch.
+// The keys of options form the priority list. Since map iteration order is not
+// deterministic, keys are sorted lexically to break ties between equally
+// acceptable priorities.
+func NegotiateTyped[T any](n *Negotiator, header string, options map[string]T, strict bool) (T, error) {
+	var zero T
+
+	priorities := slices.Sorted(maps.Keys(options))
+
+	best, err := n.Negotiate(header, priorities, strict)
+	if err != nil {
+		return zero, err
+	}
+
+	if best.priorityIndex < 0 {
+		return zero, ErrNoAcceptableMatch
+	}
+
+	return options[priorities[best.priorityIndex]], nil
+}
@@ -0,0 +1,86 @@
+package negotiation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateTyped(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	options := map[string]string{
+		"application/json": "json renderer",
+		"text/html":        "html renderer",
+	}
+
+	tests := []struct {
+		name         string
+		acceptHeader string
+		expected     string
+		expectErr    error
+	}{
+		{"exact match", "text/html, application/json;q=0.9", "html renderer", nil},
+		{"quality preference", "text/html;q=0.5, application/json", "json renderer", nil},
+		{"wildcard uses sorted keys", "*/*", "json renderer", nil},
+		{"no acceptable match", "image/png", "", ErrNoAcceptableMatch},
+		{"empty header", "", "", ErrEmptyHeader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := NegotiateTyped(negotiator, tt.acceptHeader, options, false)
+			if tt.expectErr != nil {
+				require.ErrorIs(t, err, tt.expectErr)
+				assert.Empty(t, result)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestNegotiateTyped_WildcardKey(t *testing.T) {
+	options := map[string]int{"application/json": 1, "*/*": 2}
+
+	result, err := NegotiateTyped(NewMediaNegotiator(), "text/html", options, false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result)
+
+	result, err = NegotiateTyped(NewMediaNegotiator(), "text/html;q=0.5, application/json", options, false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result, "*/* sorts before application/json and wins the tie")
+
+	_, err = NegotiateTyped(NewMediaNegotiator(), "text/html;q=0", options, false)
+	require.ErrorIs(t, err, ErrNoAcceptableMatch)
+}
+
+func TestNegotiateTyped_TypeWildcardKey(t *testing.T) {
+	options := map[string]string{"text/html": "html", "application/*": "binary"}
+
+	result, err := NegotiateTyped(NewMediaNegotiator(), "application/cbor", options, false)
+	require.NoError(t, err)
+	assert.Equal(t, "binary", result)
+}
+
+func TestNegotiateTyped_ImplicitIdentity(t *testing.T) {
+	negotiator := NewEncodingNegotiator(WithImplicitIdentity(true))
+
+	// The implicit identity coding is not among the keys.
+	_, err := NegotiateTyped(negotiator, "br", map[string]int{"gzip": 1}, false)
+	require.ErrorIs(t, err, ErrNoAcceptableMatch)
+
+	result, err := NegotiateTyped(negotiator, "br", map[string]int{"gzip": 1, "identity": 2}, false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result)
+}
+
+func TestNegotiateTyped_EmptyOptions(t *testing.T) {
+	result, err := NegotiateTyped(NewMediaNegotiator(), "text/html", map[string]func() string{}, false)
+	require.ErrorIs(t, err, ErrEmptyPriorities)
+	assert.Nil(t, result)
+}
@@ -2,6 +2,9 @@ package negotiation
 
 import (
 	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
 	"sort"
 	"strings"
 )
@@ -15,22 +18,129 @@ type Header struct {
 	Type string
 	// Quality is the quality value (q-value), defaulting to 1.0.
 	Quality float64
-	// Parameters contains all parameters except 'q'.
+	// QualityExplicit reports whether the quality was given by a q parameter
+	// rather than defaulted (e.g. "q=1" versus no q at all).
+	QualityExplicit bool
+	// Parameters contains all parameters except 'q'. Names are lowercased,
+	// values keep their original case (e.g. a multipart boundary).
 	Parameters map[string]string
+	// Extensions contains the accept-ext parameters following the q parameter.
+	// Only set in RFC 7231 strict mode, see WithRFC7231Strict; they never affect matching.
+	Extensions map[string]string
 	// BasePart is the base part (e.g., "text" from "text/html", "en" from "en-US").
 	// Empty for types that don't use base/sub parts.
 	BasePart string
 	// SubPart is the sub part (e.g., "html" from "text/html", "US" from "en-US").
 	// Empty for types that don't use base/sub parts.
 	SubPart string
+	// ScriptPart is the script subtag of a language tag (e.g. "hans" from "zh-Hans-CN").
+	// Empty for non-language headers and tags without a script.
+	ScriptPart string
 
-	// NormalizedValue is the normalized value with sorted parameters.
+	// Start and End are the byte offsets of the element in the original header
+	// string, so that header[Start:End] is the element without surrounding whitespace.
+	// They are only set on elements parsed from a header, not on priorities.
+	Start int
+	End   int
+
+	// MatchedVia tells how the client header matched this priority.
+	// It is only set on Headers returned by Negotiate.
+	MatchedVia MatchKind
+
+	// NormalizedValue is the normalized value with sorted parameters. Language tags
+	// use the canonical BCP 47 subtag casing (e.g. "zh-Hans-CN"), while Type stays lowercase.
 	NormalizedValue string
 
 	// originalIndex is the original position in the header string (for stable sorting).
 	originalIndex int
+
+	// givenType is the type with the casing it was given in, if that differs from
+	// Type only in case (for WithPreserveCase).
+	givenType string
+
+	// weight is the server preference of a priority (for tie-breaking).
+	weight float64
+
+	// cost is the production cost hint of a priority (for tie-breaking).
+	cost float64
+
+	// priorityIndex is the position in the given priority list of the priority this
+	// one stems from, the wildcard for priorities expanded from one, or -1 for a
+	// priority added by negotiation (for mapping results back to the given priorities).
+	priorityIndex int
+}
+
+// String returns the normalized value of the header, see NormalizedValue.
+func (h *Header) String() string {
+	return h.NormalizedValue
 }
 
+// Equal reports whether h and other describe the same element: equal Value, Type,
+// BasePart, SubPart, Quality and Parameters, a nil and an empty parameter map
+// being equal. The position of an element in its header, used internally to keep
+// ordering stable, is deliberately ignored, so elements parsed from different
+// headers compare equal. Two nil headers are equal.
+func (h *Header) Equal(other *Header) bool {
+	if h == nil || other == nil {
+		return h == other
+	}
+
+	return h.Value == other.Value &&
+		h.Type == other.Type &&
+		h.BasePart == other.BasePart &&
+		h.SubPart == other.SubPart &&
+		h.Quality == other.Quality &&
+		maps.Equal(h.Parameters, other.Parameters)
+}
+
+// LogValue implements slog.LogValuer, so headers log as a group of their type,
+// quality and parameters, sorted by name and omitted if there are none.
+func (h *Header) LogValue() slog.Value {
+	attrs := []slog.Attr{
+		slog.String("type", h.Type),
+		slog.Float64("quality", h.Quality),
+	}
+
+	if len(h.Parameters) > 0 {
+		params := make([]slog.Attr, 0, len(h.Parameters))
+		for _, k := range slices.Sorted(maps.Keys(h.Parameters)) {
+			params = append(params, slog.String(k, h.Parameters[k]))
+		}
+		attrs = append(attrs, slog.Attr{Key: "params", Value: slog.GroupValue(params...)})
+	}
+
+	return slog.GroupValue(attrs...)
+}
+
+// MatchKind describes how a client element matched a server priority.
+type MatchKind string
+
+const (
+	// MatchExact means the client named the priority explicitly.
+	MatchExact MatchKind = "exact"
+	// MatchTypeWildcard means the priority matched a type wildcard such as text/*.
+	MatchTypeWildcard MatchKind = "typeWildcard"
+	// MatchFullWildcard means the priority matched */* (or * for other headers),
+	// i.e. the client expressed no real preference.
+	MatchFullWildcard MatchKind = "fullWildcard"
+	// MatchSuffix means the priority matched a structured syntax suffix wildcard such as application/*+json.
+	MatchSuffix MatchKind = "suffix"
+	// MatchTree means the priority is a more general type of the client's registration
+	// tree, such as application/vnd.company+json for application/vnd.company.invoice+json.
+	// See WithTreeMatching.
+	MatchTree MatchKind = "tree"
+	// MatchLanguageFallback means the priority shares only the base language subtag
+	// with the client's language range, such as en-US for en-GB. See WithLanguageFallback.
+	MatchLanguageFallback MatchKind = "languageFallback"
+	// MatchMacrolanguage means the priority is related to the client's language
+	// range through a macrolanguage, such as nb for no. See WithMacrolanguageMatching.
+	MatchMacrolanguage MatchKind = "macrolanguage"
+	// MatchImplicit means the priority is acceptable without a client element naming
+	// it, as the identity coding is by default. There is no client element. See
+	// WithImplicitIdentity.
+	MatchImplicit MatchKind = "implicit"
+)
+
 // BuildNormalizedValue builds the normalized value string with sorted parameters.
 func buildNormalizedValue(typ string, params map[string]string) string {
 	if len(params) == 0 {
@@ -45,12 +155,61 @@ func buildNormalizedValue(typ string, params map[string]string) string {
 
 	parts := make([]string, 0, len(keys))
 	for _, k := range keys {
-		parts = append(parts, fmt.Sprintf("%s=%s", k, params[k]))
+		parts = append(parts, fmt.Sprintf("%s=%s", k, quoteValue(params[k])))
 	}
 
 	return fmt.Sprintf("%s; %s", typ, strings.Join(parts, "; "))
 }
 
+// quoteValue returns value as a token if possible, or as a quoted-string with
+// '"' and '\' escaped otherwise (RFC 7230), so it parses back to value.
+func quoteValue(value string) string {
+	if isToken(value) {
+		return value
+	}
+
+	var b strings.Builder
+	b.Grow(len(value) + 2)
+	b.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		if value[i] == '"' || value[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(value[i])
+	}
+	b.WriteByte('"')
+
+	return b.String()
+}
+
+// isToken reports whether s is a non-empty RFC 7230 token.
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') ||
+			strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0 {
+			continue
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// clone returns a copy of the header that shares no maps with it.
+func (h *Header) clone() *Header {
+	c := *h
+	c.Parameters = maps.Clone(h.Parameters)
+	c.Extensions = maps.Clone(h.Extensions)
+
+	return &c
+}
+
 // newHeader creates a new Header from a value.
 func newHeader(value, typ, basePart, subPart string, quality float64, parameters map[string]string) *Header {
 	return &Header{
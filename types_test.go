@@ -1,9 +1,12 @@
 package negotiation
 
 import (
+	"bytes"
+	"log/slog"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewHeader(t *testing.T) {
@@ -19,3 +22,69 @@ func TestNewHeader(t *testing.T) {
 	assert.Equal(t, "type; param=value", header.NormalizedValue)
 	assert.Equal(t, 0, header.originalIndex)
 }
+
+func TestHeader_LogValue(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+	elements, err := negotiator.GetOrderedElements("text/html;level=1;charset=utf-8, application/json;q=0.5")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+
+			return a
+		},
+	}))
+	logger.Info("negotiated", "first", elements[0], "second", elements[1])
+
+	assert.Equal(t,
+		"level=INFO msg=negotiated first.type=text/html first.quality=1 first.params.charset=utf-8 first.params.level=1 "+
+			"second.type=application/json second.quality=0.5\n",
+		buf.String())
+}
+
+func TestHeader_Equal(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+	first, err := negotiator.GetOrderedElements("text/html;level=1;q=0.5")
+	require.NoError(t, err)
+	second, err := negotiator.GetOrderedElements("application/json, text/html;level=1;q=0.5")
+	require.NoError(t, err)
+
+	a, b := first[0], second[1]
+	assert.NotEqual(t, a.originalIndex, b.originalIndex)
+	assert.True(t, a.Equal(b), "the position in the header is ignored")
+	assert.True(t, b.Equal(a))
+
+	tests := []struct {
+		name   string
+		modify func(h *Header)
+	}{
+		{"value", func(h *Header) { h.Value = "text/html; level=1; q=0.5" }},
+		{"type", func(h *Header) { h.Type = "text/plain" }},
+		{"base part", func(h *Header) { h.BasePart = "image" }},
+		{"sub part", func(h *Header) { h.SubPart = "plain" }},
+		{"quality", func(h *Header) { h.Quality = 0.4 }},
+		{"parameter value", func(h *Header) { h.Parameters["level"] = "2" }},
+		{"extra parameter", func(h *Header) { h.Parameters["charset"] = "utf-8" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := a.clone()
+			tt.modify(c)
+			assert.False(t, a.Equal(c))
+		})
+	}
+
+	empty := newHeader("text/html", "text/html", "text", "html", 1, nil)
+	withEmptyParams := newHeader("text/html", "text/html", "text", "html", 1, map[string]string{})
+	assert.True(t, empty.Equal(withEmptyParams))
+
+	var none *Header
+	assert.True(t, none.Equal(nil))
+	assert.False(t, none.Equal(a))
+	assert.False(t, a.Equal(nil))
+}
@@ -0,0 +1,94 @@
+package negotiation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Warning describes a likely client mistake in a header, found by Validate.
+type Warning struct {
+	// Index is the position of the element in the header, or -1 if the warning
+	// concerns the header as a whole.
+	Index int
+	// Value is the element as given, or the whole header if Index is -1.
+	Value string
+	// Message describes the problem.
+	Message string
+}
+
+// String returns the warning prefixed with the element it concerns.
+func (w Warning) String() string {
+	if w.Index < 0 {
+		return w.Message
+	}
+
+	return fmt.Sprintf("element %d %q: %s", w.Index, w.Value, w.Message)
+}
+
+// Validate inspects a header for likely client bugs and returns a warning for each,
+// in header order, for building a header linter. It reports elements negotiation
+// skips or reads differently than given: invalid elements, malformed quality
+// values and empty parameters, as well as ranges repeated within the header, in
+// particular with conflicting qualities such as "application/json;q=1,
+// application/json;q=0". Validate does not change negotiation, where the highest
+// quality of a repeated range applies. An empty header has no warnings.
+func (c *Negotiator) Validate(header string) []Warning {
+	if strings.TrimSpace(header) == "" {
+		return nil
+	}
+
+	var warnings []Warning
+	if validateFieldValue(header) != nil {
+		warnings = append(warnings, Warning{Index: -1, Value: header, Message: "control characters, rejected in strict mode"})
+	}
+
+	if hasEmptyElement(header, c.opts.commaDecimalTolerance) {
+		warnings = append(warnings, Warning{Index: -1, Value: header, Message: "empty list element, rejected in strict mode"})
+	}
+
+	seen := make(map[string]*Header)
+	count := 0
+	scanHeader(header, c.opts.commaDecimalTolerance, func(part headerPart) bool {
+		i := count
+		count++
+
+		warn := func(format string, args ...any) {
+			warnings = append(warnings, Warning{Index: i, Value: part.value, Message: fmt.Sprintf(format, args...)})
+		}
+
+		if hasEmptyParameter(part.value) {
+			warn("empty parameter, rejected in strict mode")
+		}
+
+		h, err := c.parsePart(part, i, false, false, func(err error) {
+			if qualityErr := (*InvalidQualityError)(nil); errors.As(err, &qualityErr) {
+				warn("malformed quality %q, ignored", qualityErr.Quality)
+			}
+		})
+		if err != nil {
+			warn("invalid element, skipped: %v", err)
+
+			return true
+		}
+
+		first, ok := seen[h.NormalizedValue]
+		switch {
+		case !ok:
+			seen[h.NormalizedValue] = h
+		case first.Quality != h.Quality:
+			warn("duplicate range with conflicting quality: q=%g, but q=%g at element %d",
+				h.Quality, first.Quality, first.originalIndex)
+		default:
+			warn("duplicate range, repeats element %d", first.originalIndex)
+		}
+
+		return true
+	})
+
+	if count == 0 {
+		warnings = append(warnings, Warning{Index: -1, Value: header, Message: "header has no elements"})
+	}
+
+	return warnings
+}
@@ -0,0 +1,120 @@
+package negotiation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiator_Validate(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	tests := []struct {
+		name     string
+		header   string
+		expected []Warning
+	}{
+		{
+			name:     "clean header",
+			header:   "text/html, application/json;q=0.9, */*;q=0.1",
+			expected: nil,
+		},
+		{
+			name:     "empty header",
+			header:   "  ",
+			expected: nil,
+		},
+		{
+			name:   "conflicting quality",
+			header: "application/json;q=1, application/json;q=0",
+			expected: []Warning{
+				{Index: 1, Value: "application/json;q=0", Message: "duplicate range with conflicting quality: q=0, but q=1 at element 0"},
+			},
+		},
+		{
+			name:   "conflicting quality after normalization",
+			header: "Application/JSON; Charset=utf-8, application/json;charset=utf-8;q=0.5",
+			expected: []Warning{
+				{Index: 1, Value: "application/json;charset=utf-8;q=0.5", Message: "duplicate range with conflicting quality: q=0.5, but q=1 at element 0"},
+			},
+		},
+		{
+			name:   "repeated range",
+			header: "text/html;q=0.8, application/json, text/html;q=0.8",
+			expected: []Warning{
+				{Index: 2, Value: "text/html;q=0.8", Message: "duplicate range, repeats element 0"},
+			},
+		},
+		{
+			name:     "different parameters are distinct ranges",
+			header:   "text/html;level=1, text/html;q=0",
+			expected: nil,
+		},
+		{
+			name:   "malformed quality",
+			header: "text/html;q=high, application/json",
+			expected: []Warning{
+				{Index: 0, Value: "text/html;q=high", Message: `malformed quality "high", ignored`},
+			},
+		},
+		{
+			name:   "invalid element",
+			header: "text/html, html",
+			expected: []Warning{
+				{Index: 1, Value: "html", Message: "invalid element, skipped: " + (&InvalidMediaTypeError{}).Error()},
+			},
+		},
+		{
+			name:   "empty parameter",
+			header: "text/html;;level=1",
+			expected: []Warning{
+				{Index: 0, Value: "text/html;;level=1", Message: "empty parameter, rejected in strict mode"},
+			},
+		},
+		{
+			name:   "control characters",
+			header: "text/html\x00",
+			expected: []Warning{
+				{Index: -1, Value: "text/html\x00", Message: "control characters, rejected in strict mode"},
+			},
+		},
+		{
+			name:   "no elements",
+			header: ", ,",
+			expected: []Warning{
+				{Index: -1, Value: ", ,", Message: "empty list element, rejected in strict mode"},
+				{Index: -1, Value: ", ,", Message: "header has no elements"},
+			},
+		},
+		{
+			name:   "trailing comma",
+			header: "application/json, text/html,",
+			expected: []Warning{
+				{Index: -1, Value: "application/json, text/html,", Message: "empty list element, rejected in strict mode"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, negotiator.Validate(tt.header))
+		})
+	}
+}
+
+func TestNegotiator_Validate_DoesNotChangeNegotiation(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+	header := "application/json;q=0, application/json;q=1"
+
+	assert.Len(t, negotiator.Validate(header), 1)
+
+	result, err := negotiator.Negotiate(header, []string{"application/json"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", result.Value)
+}
+
+func TestWarning_String(t *testing.T) {
+	assert.Equal(t, `element 1 "html": invalid element`, Warning{Index: 1, Value: "html", Message: "invalid element"}.String())
+	assert.Equal(t, "header has no elements", Warning{Index: -1, Value: ",", Message: "header has no elements"}.String())
+}
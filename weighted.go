@@ -0,0 +1,73 @@
+package negotiation
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+)
+
+// WeightedPriority is a server priority with a server preference weight and cost.
+// Among priorities the client accepts with equal quality, the higher weight wins,
+// then the lower cost, then the earlier priority.
+type WeightedPriority struct {
+	// Value is the priority string, e.g. "application/json".
+	Value string
+	// Weight is the server preference; higher wins ties between equally acceptable priorities.
+	Weight float64
+	// Cost is a hint of how expensive the representation is to produce, e.g. its size;
+	// lower wins ties between equally acceptable priorities of equal weight.
+	Cost float64
+}
+
+// unweighted converts plain priorities to weighted ones of equal weight.
+func unweighted(priorities []string) []WeightedPriority {
+	weighted := make([]WeightedPriority, len(priorities))
+	for i, p := range priorities {
+		weighted[i] = WeightedPriority{Value: p, Weight: 1.0}
+	}
+
+	return weighted
+}
+
+// RankedPriority is a server priority with an explicit rank. Among priorities the
+// client accepts with equal quality, the lower rank wins, whatever their order in
+// the slice.
+type RankedPriority struct {
+	// Value is the priority string, e.g. "application/json".
+	Value string
+	// Rank orders priorities; lower wins ties between equally acceptable priorities.
+	Rank int
+}
+
+// byRank converts ranked priorities to weighted ones of equal weight, ordered by
+// rank. Priorities of equal rank keep their slice order.
+func byRank(priorities []RankedPriority) []WeightedPriority {
+	ranked := slices.Clone(priorities)
+	slices.SortStableFunc(ranked, func(a, b RankedPriority) int {
+		return cmp.Compare(a.Rank, b.Rank)
+	})
+
+	weighted := make([]WeightedPriority, len(ranked))
+	for i, p := range ranked {
+		weighted[i] = WeightedPriority{Value: p.Value, Weight: 1.0}
+	}
+
+	return weighted
+}
+
+// fromMap converts priorities mapped to their server weight to weighted ones,
+// ordered by weight descending and then by value, so that the result does not
+// depend on map iteration order.
+func fromMap(priorities map[string]float64) []WeightedPriority {
+	values := slices.Sorted(maps.Keys(priorities))
+	slices.SortStableFunc(values, func(a, b string) int {
+		return cmp.Compare(priorities[b], priorities[a])
+	})
+
+	weighted := make([]WeightedPriority, len(values))
+	for i, v := range values {
+		weighted[i] = WeightedPriority{Value: v, Weight: priorities[v]}
+	}
+
+	return weighted
+}
@@ -0,0 +1,182 @@
+package negotiation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiator_NegotiateWeighted(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	tests := []struct {
+		name         string
+		acceptHeader string
+		priorities   []WeightedPriority
+		expectedType string
+	}{
+		{
+			name:         "weight breaks quality ties",
+			acceptHeader: "text/html, application/json",
+			priorities:   []WeightedPriority{{Value: "text/html", Weight: 1}, {Value: "application/json", Weight: 2}},
+			expectedType: "application/json",
+		},
+		{
+			name:         "client quality wins over weight",
+			acceptHeader: "text/html, application/json;q=0.9",
+			priorities:   []WeightedPriority{{Value: "text/html", Weight: 1}, {Value: "application/json", Weight: 5}},
+			expectedType: "text/html",
+		},
+		{
+			name:         "lower cost breaks quality ties",
+			acceptHeader: "image/png, image/webp",
+			priorities:   []WeightedPriority{{Value: "image/png", Weight: 1, Cost: 40}, {Value: "image/webp", Weight: 1, Cost: 25}},
+			expectedType: "image/webp",
+		},
+		{
+			name:         "weight wins over cost",
+			acceptHeader: "image/*",
+			priorities:   []WeightedPriority{{Value: "image/webp", Weight: 1, Cost: 1}, {Value: "image/png", Weight: 2, Cost: 10}},
+			expectedType: "image/png",
+		},
+		{
+			name:         "client quality wins over cost",
+			acceptHeader: "image/png, image/webp;q=0.9",
+			priorities:   []WeightedPriority{{Value: "image/webp", Weight: 1, Cost: 1}, {Value: "image/png", Weight: 1, Cost: 10}},
+			expectedType: "image/png",
+		},
+		{
+			name:         "order breaks cost ties",
+			acceptHeader: "image/*",
+			priorities:   []WeightedPriority{{Value: "image/png", Weight: 1, Cost: 5}, {Value: "image/webp", Weight: 1, Cost: 5}},
+			expectedType: "image/png",
+		},
+		{
+			name:         "order breaks weight ties",
+			acceptHeader: "*/*",
+			priorities:   []WeightedPriority{{Value: "text/html", Weight: 1}, {Value: "application/json", Weight: 1}},
+			expectedType: "text/html",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := negotiator.NegotiateWeighted(tt.acceptHeader, tt.priorities, false)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedType, result.Type)
+		})
+	}
+}
+
+func TestNegotiator_NegotiateRanked(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	tests := []struct {
+		name         string
+		acceptHeader string
+		priorities   []RankedPriority
+		expectedType string
+	}{
+		{
+			name:         "rank breaks quality ties against slice order",
+			acceptHeader: "text/html, application/json",
+			priorities:   []RankedPriority{{Value: "text/html", Rank: 2}, {Value: "application/json", Rank: 1}},
+			expectedType: "application/json",
+		},
+		{
+			name:         "rank breaks wildcard ties against slice order",
+			acceptHeader: "*/*",
+			priorities:   []RankedPriority{{Value: "text/html", Rank: 10}, {Value: "application/xml", Rank: 5}, {Value: "application/json", Rank: -1}},
+			expectedType: "application/json",
+		},
+		{
+			name:         "client quality wins over rank",
+			acceptHeader: "text/html, application/json;q=0.9",
+			priorities:   []RankedPriority{{Value: "text/html", Rank: 2}, {Value: "application/json", Rank: 1}},
+			expectedType: "text/html",
+		},
+		{
+			name:         "slice order breaks rank ties",
+			acceptHeader: "*/*",
+			priorities:   []RankedPriority{{Value: "text/html", Rank: 1}, {Value: "application/json", Rank: 1}},
+			expectedType: "text/html",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := negotiator.NegotiateRanked(tt.acceptHeader, tt.priorities, false)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedType, result.Type)
+		})
+	}
+
+	priorities := []RankedPriority{{Value: "text/html", Rank: 2}, {Value: "application/json", Rank: 1}}
+	_, err := negotiator.NegotiateRanked("*/*", priorities, false)
+	require.NoError(t, err)
+	assert.Equal(t, []RankedPriority{{Value: "text/html", Rank: 2}, {Value: "application/json", Rank: 1}}, priorities, "priorities are not reordered in place")
+
+	_, err = negotiator.NegotiateRanked("image/png", priorities, false)
+	require.ErrorIs(t, err, ErrNoAcceptableMatch)
+}
+
+func TestNegotiator_NegotiateMap(t *testing.T) {
+	negotiator := NewMediaNegotiator()
+
+	tests := []struct {
+		name         string
+		acceptHeader string
+		priorities   map[string]float64
+		expectedType string
+	}{
+		{
+			name:         "weight breaks quality ties",
+			acceptHeader: "text/html, application/json",
+			priorities:   map[string]float64{"text/html": 1, "application/json": 2},
+			expectedType: "application/json",
+		},
+		{
+			name:         "weight breaks wildcard ties",
+			acceptHeader: "*/*",
+			priorities:   map[string]float64{"text/html": 0.5, "application/xml": 1, "application/json": 0.8},
+			expectedType: "application/xml",
+		},
+		{
+			name:         "client quality wins over weight",
+			acceptHeader: "text/html, application/json;q=0.9",
+			priorities:   map[string]float64{"text/html": 1, "application/json": 10},
+			expectedType: "text/html",
+		},
+		{
+			name:         "equal weights fall back to value order",
+			acceptHeader: "*/*",
+			priorities:   map[string]float64{"text/html": 1, "application/xml": 1, "application/json": 1},
+			expectedType: "application/json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Map iteration order varies between runs, the result must not
+			for range 20 {
+				result, err := negotiator.NegotiateMap(tt.acceptHeader, tt.priorities, false)
+				require.NoError(t, err)
+				assert.Equal(t, tt.expectedType, result.Type)
+			}
+		})
+	}
+
+	_, err := negotiator.NegotiateMap("text/html", nil, false)
+	require.ErrorIs(t, err, ErrEmptyPriorities)
+}
+
+func TestFromMap(t *testing.T) {
+	weighted := fromMap(map[string]float64{"b": 1, "a": 1, "c": 2, "d": 0.5})
+	assert.Equal(t, []WeightedPriority{
+		{Value: "c", Weight: 2},
+		{Value: "a", Weight: 1},
+		{Value: "b", Weight: 1},
+		{Value: "d", Weight: 0.5},
+	}, weighted)
+}